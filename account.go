@@ -0,0 +1,302 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/auth"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+type exportSession struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// exportBookmark refers to the bookmarked chirp by ID only, the chirp
+// itself usually belongs to someone else.
+type exportBookmark struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// accountExport is everything stored about a user: their profile, chirps
+// including scheduled ones and deleted ones that can still be restored,
+// drafts, bookmarks, lists, direct messages, sessions and API keys.
+type accountExport struct {
+	ExportedAt      time.Time        `json:"exported_at"`
+	Profile         User             `json:"profile"`
+	Chirps          []Chirp          `json:"chirps"`
+	ScheduledChirps []Chirp          `json:"scheduled_chirps"`
+	DeletedChirps   []Chirp          `json:"deleted_chirps"`
+	Drafts          []Draft          `json:"drafts"`
+	Bookmarks       []exportBookmark `json:"bookmarks"`
+	Lists           []List           `json:"lists"`
+	Messages        []Message        `json:"messages"`
+	Sessions        []exportSession  `json:"sessions"`
+	APIKeys         []APIKey         `json:"api_keys"`
+}
+
+func (cfg *apiConfig) handlerDeleteUser(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	var params struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	dbUser, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User could not be found")
+		return
+	}
+	if err := auth.CheckPasswordHash(dbUser.HashedPassword, params.Password); err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Incorrect password")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not delete user")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	// Uploads cascade with the user row, their files are deleted once it
+	// is gone.
+	dbMedia, err := qtx.GetUserMediaFiles(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not delete the user's uploads")
+		return
+	}
+	// Chirps and API keys cascade with the user row, refresh tokens don't.
+	if err := qtx.DeleteUserRefreshTokens(r.Context(), userID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not delete the user's sessions")
+		return
+	}
+	if err := qtx.DeleteUser(r.Context(), userID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not delete user")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not delete user")
+		return
+	}
+	cfg.deleteMediaBlobs(r.Context(), dbMedia)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerExportUser(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	export, err := cfg.buildAccountExport(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not export user data")
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		utils.RespondWithJSON(w, http.StatusOK, export)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="chirpy-export.zip"`)
+	w.WriteHeader(http.StatusOK)
+
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name    string
+		payload any
+	}{
+		{"profile.json", export.Profile},
+		{"chirps.json", export.Chirps},
+		{"scheduled_chirps.json", export.ScheduledChirps},
+		{"deleted_chirps.json", export.DeletedChirps},
+		{"drafts.json", export.Drafts},
+		{"bookmarks.json", export.Bookmarks},
+		{"lists.json", export.Lists},
+		{"messages.json", export.Messages},
+		{"sessions.json", export.Sessions},
+		{"api_keys.json", export.APIKeys},
+	}
+	for _, file := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.payload); err != nil {
+			return
+		}
+	}
+	zw.Close()
+}
+
+func (cfg *apiConfig) buildAccountExport(ctx context.Context, userID uuid.UUID) (accountExport, error) {
+	dbUser, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return accountExport{}, err
+	}
+	dbChirps, err := cfg.dbQueries.GetAuthorChirps(ctx, userID)
+	if err != nil {
+		return accountExport{}, err
+	}
+	dbScheduled, err := cfg.dbQueries.GetScheduledChirps(ctx, userID)
+	if err != nil {
+		return accountExport{}, err
+	}
+	dbDeleted, err := cfg.dbQueries.GetDeletedChirps(ctx, database.GetDeletedChirpsParams{
+		UserID:       userID,
+		DeletedAfter: time.Now().UTC().Add(-chirpRestoreWindow),
+	})
+	if err != nil {
+		return accountExport{}, err
+	}
+	dbDrafts, err := cfg.dbQueries.GetDrafts(ctx, userID)
+	if err != nil {
+		return accountExport{}, err
+	}
+	dbBookmarks, err := cfg.dbQueries.GetUserBookmarks(ctx, userID)
+	if err != nil {
+		return accountExport{}, err
+	}
+	dbLists, err := cfg.dbQueries.GetListsByOwner(ctx, database.GetListsByOwnerParams{
+		OwnerID:        userID,
+		IncludePrivate: true,
+	})
+	if err != nil {
+		return accountExport{}, err
+	}
+	dbMessages, err := cfg.dbQueries.GetUserMessages(ctx, userID)
+	if err != nil {
+		return accountExport{}, err
+	}
+	dbTokens, err := cfg.dbQueries.GetUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return accountExport{}, err
+	}
+	dbKeys, err := cfg.dbQueries.GetUserAPIKeys(ctx, userID)
+	if err != nil {
+		return accountExport{}, err
+	}
+
+	export := accountExport{
+		ExportedAt: time.Now().UTC(),
+		Profile:    userFromDB(dbUser),
+		Drafts:     make([]Draft, 0, len(dbDrafts)),
+		Bookmarks:  make([]exportBookmark, 0, len(dbBookmarks)),
+		Messages:   make([]Message, 0, len(dbMessages)),
+		Sessions:   make([]exportSession, 0, len(dbTokens)),
+		APIKeys:    make([]APIKey, 0, len(dbKeys)),
+	}
+	export.Chirps, err = cfg.chirpsFromDB(ctx, dbChirps)
+	if err != nil {
+		return accountExport{}, err
+	}
+	export.ScheduledChirps, err = cfg.chirpsFromDB(ctx, dbScheduled)
+	if err != nil {
+		return accountExport{}, err
+	}
+	export.DeletedChirps, err = cfg.chirpsFromDB(ctx, dbDeleted)
+	if err != nil {
+		return accountExport{}, err
+	}
+	for _, dbDraft := range dbDrafts {
+		export.Drafts = append(export.Drafts, draftFromDB(dbDraft))
+	}
+	for _, dbBookmark := range dbBookmarks {
+		export.Bookmarks = append(export.Bookmarks, exportBookmark{
+			ChirpID:   dbBookmark.ChirpID,
+			CreatedAt: dbBookmark.CreatedAt,
+		})
+	}
+
+	export.Lists, err = cfg.listsFromDB(ctx, dbLists)
+	if err != nil {
+		return accountExport{}, err
+	}
+	for i := range export.Lists {
+		memberIDs, err := cfg.dbQueries.GetListMembers(ctx, export.Lists[i].ID)
+		if err != nil {
+			return accountExport{}, err
+		}
+		members, err := cfg.authorsByID(ctx, memberIDs)
+		if err != nil {
+			return accountExport{}, err
+		}
+		export.Lists[i].Members = make([]*Author, 0, len(memberIDs))
+		for _, memberID := range memberIDs {
+			export.Lists[i].Members = append(export.Lists[i].Members, members[memberID])
+		}
+	}
+
+	// Messages from the other members are included, they are part of the
+	// user's conversations.
+	var conversationIDs []uuid.UUID
+	for _, dbMessage := range dbMessages {
+		if !slices.Contains(conversationIDs, dbMessage.ConversationID) {
+			conversationIDs = append(conversationIDs, dbMessage.ConversationID)
+		}
+	}
+	dbMembers, err := cfg.dbQueries.GetConversationMembers(ctx, conversationIDs)
+	if err != nil {
+		return accountExport{}, err
+	}
+	for _, dbMessage := range dbMessages {
+		export.Messages = append(export.Messages, messageFromDB(dbMessage, dbMembers))
+	}
+	// Token values are left out on purpose, an export shouldn't be a way to
+	// hand live sessions to whoever gets hold of the archive.
+	for _, dbToken := range dbTokens {
+		export.Sessions = append(export.Sessions, exportSession{
+			CreatedAt: dbToken.CreatedAt,
+			ExpiresAt: dbToken.ExpiresAt,
+			RevokedAt: nullTimePtr(dbToken.RevokedAt),
+		})
+	}
+	for _, dbKey := range dbKeys {
+		export.APIKeys = append(export.APIKeys, apiKeyFromDB(dbKey))
+	}
+
+	return export, nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+const (
+	defaultAnalyticsDays = 30
+	maxAnalyticsDays     = 365
+	// Hourly buckets are only offered for short ranges to keep responses
+	// small.
+	maxHourlyAnalyticsDays = 7
+)
+
+// analyticsBuckets maps each bucket size to how long it is. The names are
+// the ones date_trunc understands.
+var analyticsBuckets = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+type AnalyticsTotals struct {
+	Views        int64 `json:"views"`
+	Likes        int64 `json:"likes"`
+	Replies      int64 `json:"replies"`
+	NewFollowers int64 `json:"new_followers"`
+}
+
+type AnalyticsBucket struct {
+	Start time.Time `json:"start"`
+	AnalyticsTotals
+}
+
+type Analytics struct {
+	Bucket    string            `json:"bucket"`
+	From      time.Time         `json:"from"`
+	To        time.Time         `json:"to"`
+	Followers int64             `json:"followers"`
+	Totals    AnalyticsTotals   `json:"totals"`
+	Buckets   []AnalyticsBucket `json:"buckets"`
+}
+
+func (t *AnalyticsTotals) add(metric string, n int64) {
+	switch metric {
+	case "views":
+		t.Views += n
+	case "likes":
+		t.Likes += n
+	case "replies":
+		t.Replies += n
+	case "new_followers":
+		t.NewFollowers += n
+	}
+}
+
+// recordViews counts a view of each chirp served to the viewer. Authors
+// viewing their own chirps aren't counted. The counts are saved in batches
+// by runImpressionFlush.
+func (cfg *apiConfig) recordViews(viewerID uuid.UUID, dbChirps []database.Chirp) {
+	ids := make([]uuid.UUID, 0, len(dbChirps))
+	for _, dbChirp := range dbChirps {
+		if dbChirp.UserID != viewerID {
+			ids = append(ids, dbChirp.ID)
+		}
+	}
+	cfg.impressions.Add(ids...)
+}
+
+// flushImpressions saves the views counted since the last flush. If they
+// can't be saved they are kept for the next attempt.
+func (cfg *apiConfig) flushImpressions(ctx context.Context) error {
+	counts := cfg.impressions.Take()
+	if len(counts) == 0 {
+		return nil
+	}
+
+	params := database.RecordChirpViewsParams{
+		ChirpIds: make([]uuid.UUID, 0, len(counts)),
+		Views:    make([]int64, 0, len(counts)),
+	}
+	for id, n := range counts {
+		params.ChirpIds = append(params.ChirpIds, id)
+		params.Views = append(params.Views, n)
+	}
+	if err := cfg.dbQueries.RecordChirpViews(ctx, params); err != nil {
+		cfg.impressions.Restore(counts)
+		return err
+	}
+	return nil
+}
+
+func (cfg *apiConfig) runImpressionFlush(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := cfg.flushImpressions(context.Background()); err != nil {
+			log.Printf("Error saving chirp views: %v", err)
+		}
+	}
+}
+
+// truncateBucket returns the start of the bucket t falls in, the same way
+// date_trunc does. Weeks start on Monday.
+func truncateBucket(t time.Time, bucket string) time.Time {
+	switch bucket {
+	case "hour":
+		return t.Truncate(time.Hour)
+	case "week":
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+}
+
+func (cfg *apiConfig) handlerGetUserAnalytics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsRead)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	query := r.URL.Query()
+	bucket := query.Get("bucket")
+	if bucket == "" {
+		bucket = "day"
+	}
+	if _, ok := analyticsBuckets[bucket]; !ok {
+		utils.RespondWithError(w, http.StatusBadRequest, "bucket must be hour, day or week")
+		return
+	}
+	days := defaultAnalyticsDays
+	if value := query.Get("days"); value != "" {
+		n, err := fmt.Sscan(value, &days)
+		if err != nil || n != 1 || days < 1 || days > maxAnalyticsDays {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("days must be between 1 and %d", maxAnalyticsDays))
+			return
+		}
+	}
+	if bucket == "hour" && days > maxHourlyAnalyticsDays {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("hourly buckets are limited to %d days", maxHourlyAnalyticsDays))
+		return
+	}
+
+	now := time.Now().UTC()
+	from := truncateBucket(now.AddDate(0, 0, -days), bucket)
+	rows, err := cfg.dbQueries.GetUserAnalytics(r.Context(), database.GetUserAnalyticsParams{
+		Bucket: bucket,
+		UserID: userID,
+		Since:  from,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get analytics")
+		return
+	}
+	stats, err := cfg.dbQueries.GetUserStats(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get analytics")
+		return
+	}
+
+	// Every bucket in the range is returned, including empty ones, so
+	// clients can chart the result as is.
+	analytics := Analytics{
+		Bucket:    bucket,
+		From:      from,
+		To:        now,
+		Followers: stats.FollowerCount,
+		Buckets:   []AnalyticsBucket{},
+	}
+	index := make(map[time.Time]int)
+	for start := from; !start.After(now); start = truncateBucket(start.Add(analyticsBuckets[bucket]), bucket) {
+		index[start] = len(analytics.Buckets)
+		analytics.Buckets = append(analytics.Buckets, AnalyticsBucket{Start: start})
+	}
+	for _, row := range rows {
+		analytics.Totals.add(row.Metric, row.Total)
+		if i, ok := index[row.BucketStart.UTC()]; ok {
+			analytics.Buckets[i].add(row.Metric, row.Total)
+		}
+	}
+	utils.RespondWithJSON(w, http.StatusOK, analytics)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/internal/impressions"
+)
+
+func TestTruncateBucket(t *testing.T) {
+	// A Wednesday afternoon.
+	at := time.Date(2024, 5, 15, 14, 35, 10, 0, time.UTC)
+	tests := []struct {
+		bucket string
+		want   time.Time
+	}{
+		{"hour", time.Date(2024, 5, 15, 14, 0, 0, 0, time.UTC)},
+		{"day", time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)},
+		{"week", time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := truncateBucket(at, tt.bucket); !got.Equal(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.bucket, tt.want, got)
+		}
+	}
+
+	// Weeks starting on a Sunday still go back to the Monday before.
+	sunday := time.Date(2024, 5, 19, 23, 0, 0, 0, time.UTC)
+	if got := truncateBucket(sunday, "week"); !got.Equal(time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected Sunday to fall in the week of May 13, got %v", got)
+	}
+}
+
+func TestRecordViewsSkipsAuthor(t *testing.T) {
+	cfg := &apiConfig{impressions: impressions.NewCounter()}
+	author, viewer := uuid.New(), uuid.New()
+	dbChirps := []database.Chirp{{ID: uuid.New(), UserID: author}, {ID: uuid.New(), UserID: viewer}}
+
+	cfg.recordViews(viewer, dbChirps)
+	cfg.recordViews(uuid.Nil, dbChirps[:1])
+
+	counts := cfg.impressions.Take()
+	if counts[dbChirps[0].ID] != 2 {
+		t.Errorf("expected 2 views of the other author's chirp, got %d", counts[dbChirps[0].ID])
+	}
+	if n, ok := counts[dbChirps[1].ID]; ok {
+		t.Errorf("expected the viewer's own chirp not to be counted, got %d", n)
+	}
+}
+
+func TestUserAnalyticsTotals(t *testing.T) {
+	cfg := newTestConfig(t)
+	author, token := createTestUser(t, cfg)
+	replier, _ := createTestUser(t, cfg)
+	dbChirp := createTestChirp(t, cfg, author.ID, "count me")
+
+	cfg.recordViews(replier.ID, []database.Chirp{dbChirp})
+	cfg.recordViews(uuid.Nil, []database.Chirp{dbChirp})
+	cfg.recordViews(author.ID, []database.Chirp{dbChirp})
+	if err := cfg.flushImpressions(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only replies by other users are counted.
+	for _, userID := range []uuid.UUID{replier.ID, author.ID} {
+		_, err := cfg.dbQueries.CreateChirps(context.Background(), database.CreateChirpsParams{
+			Body:      "reply",
+			UserID:    userID,
+			InReplyTo: uuid.NullUUID{UUID: dbChirp.ID, Valid: true},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := serveTestRecorder(cfg.handlerGetUserAnalytics, http.MethodGet, "/api/users/analytics", "/api/users/analytics?bucket=hour&days=1", token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var analytics Analytics
+	if err := json.NewDecoder(rec.Body).Decode(&analytics); err != nil {
+		t.Fatal(err)
+	}
+	if analytics.Totals.Views != 2 || analytics.Totals.Replies != 1 || analytics.Totals.Likes != 0 {
+		t.Errorf("expected 2 views and 1 reply, got %+v", analytics.Totals)
+	}
+
+	var sum AnalyticsTotals
+	for _, bucket := range analytics.Buckets {
+		sum.add("views", bucket.Views)
+		sum.add("replies", bucket.Replies)
+	}
+	if sum.Views != analytics.Totals.Views || sum.Replies != analytics.Totals.Replies {
+		t.Errorf("expected the buckets to add up to the totals, got %+v", sum)
+	}
+	if len(analytics.Buckets) < 24 {
+		t.Errorf("expected a bucket for every hour, got %d", len(analytics.Buckets))
+	}
+}
+
+func TestUserAnalyticsRejectsLongHourlyRanges(t *testing.T) {
+	cfg := newTestConfig(t)
+	_, token := createTestUser(t, cfg)
+
+	status := serveTestRequest(cfg.handlerGetUserAnalytics, http.MethodGet, "/api/users/analytics", "/api/users/analytics?bucket=hour&days=30", token, "")
+	if status != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", status)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/auth"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+func apiKeyFromDB(dbKey database.ApiKey) APIKey {
+	return APIKey{
+		ID:         dbKey.ID,
+		CreatedAt:  dbKey.CreatedAt,
+		Name:       dbKey.Name,
+		Prefix:     dbKey.KeyPrefix,
+		Scopes:     dbKey.Scopes,
+		ExpiresAt:  nullTimePtr(dbKey.ExpiresAt),
+		LastUsedAt: nullTimePtr(dbKey.LastUsedAt),
+		RevokedAt:  nullTimePtr(dbKey.RevokedAt),
+	}
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+func (cfg *apiConfig) handlerCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	// API keys can't be used to mint more API keys.
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	var params struct {
+		Name      string   `json:"name"`
+		Scopes    []string `json:"scopes"`
+		ExpiresIn int      `json:"expires_in_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if params.Name == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "API key needs a name")
+		return
+	}
+	if len(params.Scopes) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "API key needs at least one scope")
+		return
+	}
+	for _, scope := range params.Scopes {
+		if !slices.Contains(validScopes, scope) {
+			utils.RespondWithError(w, http.StatusBadRequest, "Unknown scope: "+scope)
+			return
+		}
+	}
+
+	var expiresAt sql.NullTime
+	if params.ExpiresIn > 0 {
+		expiresAt = sql.NullTime{
+			Time:  time.Now().Add(time.Duration(params.ExpiresIn) * time.Second),
+			Valid: true,
+		}
+	}
+
+	apiKey, err := auth.MakeAPIKey()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating API key")
+		return
+	}
+
+	dbKey, err := cfg.dbQueries.CreateAPIKey(r.Context(), database.CreateAPIKeyParams{
+		UserID:    userID,
+		Name:      params.Name,
+		KeyPrefix: apiKey[:len(auth.APIKeyPrefix)+8],
+		KeyHash:   auth.HashAPIKey(apiKey),
+		Scopes:    params.Scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not save API key")
+		return
+	}
+
+	// The plaintext key is only ever returned here.
+	response := struct {
+		APIKey
+		Key string `json:"key"`
+	}{
+		APIKey: apiKeyFromDB(dbKey),
+		Key:    apiKey,
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, response)
+}
+
+func (cfg *apiConfig) handlerGetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbKeys, err := cfg.dbQueries.GetUserAPIKeys(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get API keys")
+		return
+	}
+
+	keys := make([]APIKey, 0, len(dbKeys))
+	for _, dbKey := range dbKeys {
+		keys = append(keys, apiKeyFromDB(dbKey))
+	}
+	utils.RespondWithJSON(w, http.StatusOK, keys)
+}
+
+func (cfg *apiConfig) handlerRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	keyID, err := uuid.Parse(r.PathValue("keyID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	revoked, err := cfg.dbQueries.RevokeAPIKey(r.Context(), database.RevokeAPIKeyParams{
+		ID:     keyID,
+		UserID: userID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not revoke API key")
+		return
+	}
+	if revoked == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "API key could not be found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/auth"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+const (
+	scopeChirpsRead  = "chirps:read"
+	scopeChirpsWrite = "chirps:write"
+)
+
+var validScopes = []string{scopeChirpsRead, scopeChirpsWrite}
+
+// queryTokenPaths are the routes that accept an access token in the
+// ?access_token query parameter, for clients that can't set headers.
+var queryTokenPaths = []string{"/api/ws"}
+
+var (
+	errNotAdmin      = errors.New("user is not an admin")
+	errAccountBanned = errors.New("account has been banned")
+)
+
+// suspendedError is returned for users who are suspended until a given time.
+type suspendedError struct {
+	Until time.Time
+}
+
+func (e suspendedError) Error() string {
+	return "account is suspended until " + e.Until.UTC().Format(time.RFC3339)
+}
+
+// authenticate resolves the user behind a request. It accepts either a JWT
+// access token ("Authorization: Bearer ...") or a personal API key
+// ("Authorization: ApiKey ...") carrying the given scope.
+func (cfg *apiConfig) authenticate(r *http.Request, scope string) (uuid.UUID, error) {
+	if apiKey, err := auth.GetAPIKey(r.Header); err == nil {
+		return cfg.authenticateAPIKey(r.Context(), apiKey, scope)
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return auth.ValidateJWT(token, cfg.secret)
+}
+
+// viewer authenticates a request that may also be made anonymously. It
+// returns uuid.Nil when no credentials were sent.
+func (cfg *apiConfig) viewer(r *http.Request) (uuid.UUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.Nil, nil
+	}
+	return cfg.authenticate(r, scopeChirpsRead)
+}
+
+// checkAPIKeyUsable returns an error for keys that were revoked or have
+// expired.
+func checkAPIKeyUsable(dbKey database.ApiKey) error {
+	if dbKey.RevokedAt.Valid {
+		return errors.New("API key has been revoked")
+	}
+	if dbKey.ExpiresAt.Valid && time.Now().After(dbKey.ExpiresAt.Time) {
+		return errors.New("API key has expired")
+	}
+	return nil
+}
+
+func (cfg *apiConfig) authenticateAPIKey(ctx context.Context, apiKey, scope string) (uuid.UUID, error) {
+	dbKey, err := cfg.dbQueries.GetAPIKeyByHash(ctx, auth.HashAPIKey(apiKey))
+	if err != nil {
+		return uuid.Nil, errors.New("unknown API key")
+	}
+	if err := checkAPIKeyUsable(dbKey); err != nil {
+		return uuid.Nil, err
+	}
+	if !slices.Contains(dbKey.Scopes, scope) {
+		return uuid.Nil, errors.New("API key is missing the required scope")
+	}
+
+	if err := cfg.dbQueries.TouchAPIKey(ctx, dbKey.ID); err != nil {
+		return uuid.Nil, err
+	}
+	return dbKey.UserID, nil
+}
+
+// requireAdmin authenticates a request with a JWT access token and checks
+// that it belongs to an admin.
+func (cfg *apiConfig) requireAdmin(r *http.Request) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	dbUser, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !dbUser.IsAdmin {
+		return uuid.Nil, errNotAdmin
+	}
+	return userID, nil
+}
+
+// checkAccountStatus returns errAccountBanned or a suspendedError when the
+// user may not use their account.
+func (cfg *apiConfig) checkAccountStatus(ctx context.Context, userID uuid.UUID) error {
+	status, err := cfg.dbQueries.GetUserAccountStatus(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if status.BannedAt.Valid {
+		return errAccountBanned
+	}
+	if status.SuspendedUntil.Valid && status.SuspendedUntil.Time.After(time.Now()) {
+		return suspendedError{Until: status.SuspendedUntil.Time}
+	}
+	return nil
+}
+
+func respondWithAccountError(w http.ResponseWriter, err error) {
+	var suspended suspendedError
+	switch {
+	case errors.Is(err, errAccountBanned):
+		utils.RespondWithError(w, http.StatusForbidden, "Account has been banned")
+	case errors.As(err, &suspended):
+		utils.RespondWithError(w, http.StatusForbidden, "Account is suspended until "+suspended.Until.UTC().Format(time.RFC3339))
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not check the account")
+	}
+}
+
+// requestUserID returns the user whose credentials a request carries,
+// without checking API key scopes. Requests without valid credentials are
+// left for the handler to turn away.
+func (cfg *apiConfig) requestUserID(r *http.Request) (uuid.UUID, bool) {
+	if apiKey, err := auth.GetAPIKey(r.Header); err == nil {
+		dbKey, err := cfg.dbQueries.GetAPIKeyByHash(r.Context(), auth.HashAPIKey(apiKey))
+		if err != nil || checkAPIKeyUsable(dbKey) != nil {
+			return uuid.Nil, false
+		}
+		return dbKey.UserID, true
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil && slices.Contains(queryTokenPaths, r.URL.Path) {
+		token = r.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		return uuid.Nil, false
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// middlewareAccountStatus turns away every authenticated request from a
+// banned or suspended user. Access tokens stay valid until they expire, so
+// the account is checked on each request rather than only at login.
+func (cfg *apiConfig) middlewareAccountStatus(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userID, ok := cfg.requestUserID(r); ok {
+			if err := cfg.checkAccountStatus(r.Context(), userID); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				respondWithAccountError(w, err)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func respondWithAuthError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNotAdmin) {
+		utils.RespondWithError(w, http.StatusForbidden, "Admin access required")
+		return
+	}
+	utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+// Blocking hides both users' chirps from each other and stops them from
+// following, replying to, mentioning or messaging each other. Muting only
+// hides the muted user's chirps from the viewer's feeds.
+
+// ListedUser is an entry in a user's block or mute list.
+type ListedUser struct {
+	User      *Author   `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// hiddenUsers returns the users whose chirps are left out of the viewer's
+// feeds. Anonymous viewers see everyone.
+func (cfg *apiConfig) hiddenUsers(ctx context.Context, viewerID uuid.UUID) ([]uuid.UUID, error) {
+	if viewerID == uuid.Nil {
+		return nil, nil
+	}
+	return cfg.dbQueries.GetHiddenUserIDs(ctx, viewerID)
+}
+
+// isBlocked reports whether either user has blocked the other.
+func (cfg *apiConfig) isBlocked(ctx context.Context, userID, otherID uuid.UUID) (bool, error) {
+	if userID == uuid.Nil || userID == otherID {
+		return false, nil
+	}
+	return cfg.dbQueries.HasBlockBetween(ctx, database.HasBlockBetweenParams{
+		UserID:   userID,
+		OtherIds: []uuid.UUID{otherID},
+	})
+}
+
+// listedUsers embeds the public profiles of the users in a block or mute
+// list.
+func (cfg *apiConfig) listedUsers(ctx context.Context, ids []uuid.UUID, createdAt []time.Time) ([]ListedUser, error) {
+	users, err := cfg.authorsByID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	listed := make([]ListedUser, 0, len(ids))
+	for i, id := range ids {
+		listed = append(listed, ListedUser{
+			User:      users[id],
+			CreatedAt: createdAt[i],
+		})
+	}
+	return listed, nil
+}
+
+// targetUser authenticates the request and resolves the {handle} it acts
+// on, which can't be the user themselves.
+func (cfg *apiConfig) targetUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	handle := strings.ToLower(r.PathValue("handle"))
+	target, err := cfg.dbQueries.GetUserByHandle(r.Context(), sql.NullString{String: handle, Valid: true})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User could not be found")
+		return uuid.Nil, uuid.Nil, false
+	}
+	if target.ID == userID {
+		utils.RespondWithError(w, http.StatusBadRequest, "Users can't block or mute themselves")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, target.ID, true
+}
+
+func (cfg *apiConfig) handlerBlockUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, blockedID, ok := cfg.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Blocking someone also ends follows in both directions.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not block user")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	err = qtx.BlockUser(r.Context(), database.BlockUserParams{
+		BlockerID: userID,
+		BlockedID: blockedID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not block user")
+		return
+	}
+	for _, follow := range []database.UnfollowUserParams{
+		{FollowerID: userID, FolloweeID: blockedID},
+		{FollowerID: blockedID, FolloweeID: userID},
+	} {
+		if err := qtx.UnfollowUser(r.Context(), follow); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not block user")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not block user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnblockUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, blockedID, ok := cfg.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.dbQueries.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: userID,
+		BlockedID: blockedID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not unblock user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetBlocks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsRead)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbBlocks, err := cfg.dbQueries.GetBlocks(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get blocked users")
+		return
+	}
+	ids := make([]uuid.UUID, 0, len(dbBlocks))
+	createdAt := make([]time.Time, 0, len(dbBlocks))
+	for _, dbBlock := range dbBlocks {
+		ids = append(ids, dbBlock.BlockedID)
+		createdAt = append(createdAt, dbBlock.CreatedAt)
+	}
+
+	blocked, err := cfg.listedUsers(r.Context(), ids, createdAt)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get blocked users")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, blocked)
+}
+
+func (cfg *apiConfig) handlerMuteUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, mutedID, ok := cfg.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.dbQueries.MuteUser(r.Context(), database.MuteUserParams{
+		MuterID: userID,
+		MutedID: mutedID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not mute user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnmuteUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, mutedID, ok := cfg.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.dbQueries.UnmuteUser(r.Context(), database.UnmuteUserParams{
+		MuterID: userID,
+		MutedID: mutedID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not unmute user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetMutes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsRead)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbMutes, err := cfg.dbQueries.GetMutes(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get muted users")
+		return
+	}
+	ids := make([]uuid.UUID, 0, len(dbMutes))
+	createdAt := make([]time.Time, 0, len(dbMutes))
+	for _, dbMute := range dbMutes {
+		ids = append(ids, dbMute.MutedID)
+		createdAt = append(createdAt, dbMute.CreatedAt)
+	}
+
+	muted, err := cfg.listedUsers(r.Context(), ids, createdAt)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get muted users")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, muted)
+}
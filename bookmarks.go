@@ -0,0 +1,171 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+const (
+	defaultBookmarksLimit = 20
+	maxBookmarksLimit     = 100
+)
+
+// Bookmark is a chirp the user saved for later. Bookmarks are private.
+type Bookmark struct {
+	CreatedAt time.Time `json:"created_at"`
+	Chirp     Chirp     `json:"chirp"`
+}
+
+func (cfg *apiConfig) handlerBookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp could not be found")
+		return
+	}
+	blocked, err := cfg.isBlocked(r.Context(), userID, dbChirp.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not bookmark chirp")
+		return
+	}
+	if blocked || !chirpVisible(dbChirp, userID) {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp could not be found")
+		return
+	}
+
+	err = cfg.dbQueries.BookmarkChirp(r.Context(), database.BookmarkChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not bookmark chirp")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRemoveBookmark(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	err = cfg.dbQueries.RemoveBookmark(r.Context(), database.RemoveBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not remove bookmark")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetBookmarks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsRead)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	before, limit, err := parsePageParams(r, defaultBookmarksLimit, maxBookmarksLimit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbBookmarks, err := cfg.dbQueries.GetBookmarks(r.Context(), database.GetBookmarksParams{
+		UserID:     userID,
+		Before:     before,
+		MaxResults: limit,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get bookmarks")
+		return
+	}
+	chirpIDs := make([]uuid.UUID, 0, len(dbBookmarks))
+	for _, dbBookmark := range dbBookmarks {
+		chirpIDs = append(chirpIDs, dbBookmark.ChirpID)
+	}
+	dbChirps, err := cfg.dbQueries.GetChirpsByIDs(r.Context(), chirpIDs)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get bookmarks")
+		return
+	}
+	hidden, err := cfg.hiddenUsers(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get bookmarks")
+		return
+	}
+	// Bookmarked chirps stay out of sight while their author is blocked or
+	// muted, or while a moderator has them hidden.
+	dbChirps = slices.DeleteFunc(dbChirps, func(dbChirp database.Chirp) bool {
+		return slices.Contains(hidden, dbChirp.UserID) || !chirpVisible(dbChirp, userID)
+	})
+	chirps, err := cfg.chirpsFromDB(r.Context(), dbChirps)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get bookmarks")
+		return
+	}
+	if err := cfg.addViewerVotes(r.Context(), chirps, userID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get bookmarks")
+		return
+	}
+	chirpsByID := make(map[uuid.UUID]Chirp, len(chirps))
+	for _, chirp := range chirps {
+		chirpsByID[chirp.ID] = chirp
+	}
+
+	// next_before is the cursor for the following page, if there may be one.
+	response := struct {
+		Bookmarks  []Bookmark `json:"bookmarks"`
+		NextBefore *time.Time `json:"next_before"`
+	}{
+		Bookmarks: make([]Bookmark, 0, len(dbBookmarks)),
+	}
+	for _, dbBookmark := range dbBookmarks {
+		chirp, ok := chirpsByID[dbBookmark.ChirpID]
+		if !ok {
+			continue
+		}
+		response.Bookmarks = append(response.Bookmarks, Bookmark{
+			CreatedAt: dbBookmark.CreatedAt,
+			Chirp:     chirp,
+		})
+	}
+	if len(dbBookmarks) == int(limit) {
+		response.NextBefore = &dbBookmarks[len(dbBookmarks)-1].CreatedAt
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/tsyrdev/chirpy/internal/database"
+)
+
+// countTestBookmarks returns how many bookmarks the user behind token sees.
+func countTestBookmarks(t *testing.T, cfg *apiConfig, token string) int {
+	t.Helper()
+	rec := serveTestRecorder(cfg.handlerGetBookmarks, http.MethodGet, "/api/bookmarks", "/api/bookmarks", token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var response struct {
+		Bookmarks []Bookmark `json:"bookmarks"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	return len(response.Bookmarks)
+}
+
+func TestBookmarksHideMutedAuthors(t *testing.T) {
+	cfg := newTestConfig(t)
+	user, token := createTestUser(t, cfg)
+	author, _ := createTestUser(t, cfg)
+	dbChirp := createTestChirp(t, cfg, author.ID, "keep this")
+
+	path := "/api/chirps/" + dbChirp.ID.String() + "/bookmark"
+	if status := serveTestRequest(cfg.handlerBookmarkChirp, http.MethodPost, "/api/chirps/{chirpID}/bookmark", path, token, ""); status != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", status)
+	}
+	if n := countTestBookmarks(t, cfg, token); n != 1 {
+		t.Fatalf("expected 1 bookmark, got %d", n)
+	}
+
+	err := cfg.dbQueries.MuteUser(context.Background(), database.MuteUserParams{
+		MuterID: user.ID,
+		MutedID: author.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countTestBookmarks(t, cfg, token); n != 0 {
+		t.Errorf("expected the muted author's bookmark to be hidden, got %d", n)
+	}
+}
+
+func TestCannotBookmarkChirpOfBlockedUser(t *testing.T) {
+	cfg := newTestConfig(t)
+	user, token := createTestUser(t, cfg)
+	author, _ := createTestUser(t, cfg)
+	dbChirp := createTestChirp(t, cfg, author.ID, "not for you")
+
+	err := cfg.dbQueries.BlockUser(context.Background(), database.BlockUserParams{
+		BlockerID: author.ID,
+		BlockedID: user.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := "/api/chirps/" + dbChirp.ID.String() + "/bookmark"
+	if status := serveTestRequest(cfg.handlerBookmarkChirp, http.MethodPost, "/api/chirps/{chirpID}/bookmark", path, token, ""); status != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", status)
+	}
+}
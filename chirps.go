@@ -1,25 +1,138 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/tsyrdev/chirpy/internal/auth"
 	"github.com/tsyrdev/chirpy/internal/database"
 	"github.com/tsyrdev/chirpy/utils"
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	InReplyTo *uuid.UUID `json:"in_reply_to"`
+	Author    *Author    `json:"author"`
+	Media     []Media    `json:"media"`
+	Poll      *Poll      `json:"poll,omitempty"`
+	HiddenAt  *time.Time `json:"hidden_at,omitempty"`
+	Notice    string     `json:"notice,omitempty"`
+	// Links holds previews of the links in the body once they are fetched.
+	Links []LinkPreview `json:"links,omitempty"`
+	// ScheduledFor is set on chirps that haven't been published yet.
+	ScheduledFor *time.Time `json:"scheduled_for,omitempty"`
+	// DeletedAt is only set on chirps in the owner's trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type Author struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url"`
+}
+
+// authorsByID loads the public profiles of the given users.
+func (cfg *apiConfig) authorsByID(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*Author, error) {
+	dbAuthors, err := cfg.dbQueries.GetChirpAuthors(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	authors := make(map[uuid.UUID]*Author, len(dbAuthors))
+	for _, dbAuthor := range dbAuthors {
+		authors[dbAuthor.ID] = &Author{
+			ID:          dbAuthor.ID,
+			Handle:      dbAuthor.Handle.String,
+			DisplayName: dbAuthor.DisplayName,
+			AvatarURL:   dbAuthor.AvatarUrl,
+		}
+	}
+	return authors, nil
+}
+
+// chirpsFromDB converts database chirps into API responses, embedding each
+// chirp's author and media. Each is loaded with a single query.
+func (cfg *apiConfig) chirpsFromDB(ctx context.Context, dbChirps []database.Chirp) ([]Chirp, error) {
+	authorIDs := make([]uuid.UUID, 0, len(dbChirps))
+	for _, dbChirp := range dbChirps {
+		if !slices.Contains(authorIDs, dbChirp.UserID) {
+			authorIDs = append(authorIDs, dbChirp.UserID)
+		}
+	}
+
+	authors, err := cfg.authorsByID(ctx, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	chirpIDs := make([]uuid.UUID, 0, len(dbChirps))
+	for _, dbChirp := range dbChirps {
+		chirpIDs = append(chirpIDs, dbChirp.ID)
+	}
+	dbMedia, err := cfg.dbQueries.GetChirpMediaFiles(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	chirpMedia := make(map[uuid.UUID][]Media)
+	for _, m := range dbMedia {
+		chirpMedia[m.ChirpID.UUID] = append(chirpMedia[m.ChirpID.UUID], mediaFromDB(m))
+	}
+	polls, err := cfg.pollsByChirpID(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	previews, err := cfg.linkPreviewsByChirpID(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	chirps := make([]Chirp, 0, len(dbChirps))
+	for _, dbChirp := range dbChirps {
+		chirp := Chirp{
+			ID:        dbChirp.ID,
+			CreatedAt: dbChirp.CreatedAt,
+			UpdatedAt: dbChirp.UpdatedAt,
+			Body:      dbChirp.Body,
+			UserID:    dbChirp.UserID,
+			Author:    authors[dbChirp.UserID],
+			Media:     chirpMedia[dbChirp.ID],
+			Poll:      polls[dbChirp.ID],
+			Links:     previews[dbChirp.ID],
+		}
+		if dbChirp.InReplyTo.Valid {
+			chirp.InReplyTo = &dbChirp.InReplyTo.UUID
+		}
+		if dbChirp.HiddenAt.Valid {
+			chirp.HiddenAt = &dbChirp.HiddenAt.Time
+			chirp.Notice = hiddenChirpNotice
+		}
+		chirp.ScheduledFor = nullTimePtr(dbChirp.ScheduledFor)
+		chirp.DeletedAt = nullTimePtr(dbChirp.DeletedAt)
+		if chirp.Media == nil {
+			chirp.Media = []Media{}
+		}
+		chirps = append(chirps, chirp)
+	}
+	return chirps, nil
+}
+
+func (cfg *apiConfig) chirpFromDB(ctx context.Context, dbChirp database.Chirp) (Chirp, error) {
+	chirps, err := cfg.chirpsFromDB(ctx, []database.Chirp{dbChirp})
+	if err != nil {
+		return Chirp{}, err
+	}
+	return chirps[0], nil
 }
 
 func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
@@ -32,15 +145,79 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	authHeader, err := auth.GetBearerToken(r.Header)
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Server couldn't validate the access token")
+		return
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp could not be found")
+		return
+	}
+	if userID != dbChirp.UserID {
+		utils.RespondWithError(w, http.StatusForbidden, "Chirp does not belong to user")
+		return
+	}
+
+	err = cfg.dbQueries.DeleteChirp(r.Context(), database.DeleteChirpParams{
+		ID:        chirpID,
+		DeletedBy: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Server couldn't delete chirp")
+		return
+	}
+	// Deleting a scheduled chirp cancels it. Nobody has seen it yet.
+	if !dbChirp.ScheduledFor.Valid {
+		cfg.publishEvent(r.Context(), eventChirpDeleted, userID, map[string]uuid.UUID{
+			"id":      chirpID,
+			"user_id": userID,
+		})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerEditChirp(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Server couldn't extract the authorization header")
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	var params struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
 
-	userID, err := auth.ValidateJWT(authHeader, cfg.secret)
+	ent, err := cfg.userEntitlements(r.Context(), userID)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Server couldn't validate the access token")
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+	if !ent.EditChirps {
+		utils.RespondWithError(w, http.StatusForbidden, "Editing chirps requires Chirpy Red")
+		return
+	}
+	if !cfg.allowWrite(w, userID, ent) {
+		return
+	}
+	if chirpLength(params.Body) > ent.MaxChirpLength {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Chirp is too long, the limit is %d characters", ent.MaxChirpLength))
 		return
 	}
 
@@ -54,12 +231,62 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := cfg.dbQueries.DeleteChirp(r.Context(), chirpID); err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Server couldn't delete chirp")
+	// The chirp's links are replaced along with its body.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not update the chirp")
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
 
-	w.WriteHeader(http.StatusNoContent)
+	dbChirp, err = qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:   chirpID,
+		Body: cleanChirp(params.Body),
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not update the chirp")
+		return
+	}
+	if err := qtx.DeleteChirpLinks(r.Context(), chirpID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not update the chirp")
+		return
+	}
+	dbChirp, err = cfg.storeLinks(r.Context(), qtx, dbChirp)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not update the chirp")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not update the chirp")
+		return
+	}
+
+	chirp, err := cfg.chirpFromDB(r.Context(), dbChirp)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the Chirp's author")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, chirp)
+}
+
+// canViewChirp reports whether a single chirp can be shown to viewerID,
+// which is uuid.Nil for anonymous requests. Besides chirpVisible, chirps are
+// hidden between users who blocked each other, and chirps by banned users
+// are hidden like GetAllChirps does.
+func (cfg *apiConfig) canViewChirp(ctx context.Context, dbChirp database.Chirp, viewerID uuid.UUID) (bool, error) {
+	if !chirpVisible(dbChirp, viewerID) {
+		return false, nil
+	}
+	blocked, err := cfg.isBlocked(ctx, viewerID, dbChirp.UserID)
+	if err != nil || blocked {
+		return false, err
+	}
+	author, err := cfg.dbQueries.GetUserAccountStatus(ctx, dbChirp.UserID)
+	if err != nil {
+		return false, err
+	}
+	return !author.BannedAt.Valid, nil
 }
 
 func (cfg *apiConfig) handlerGetChirp(w http.ResponseWriter, r *http.Request) {
@@ -72,45 +299,79 @@ func (cfg *apiConfig) handlerGetChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	viewerID, err := cfg.viewer(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
 	dbChirp, err := cfg.dbQueries.GetChirp(r.Context(), id)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the Chirp")
 		return
 	}
+	visible, err := cfg.canViewChirp(r.Context(), dbChirp, viewerID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the Chirp")
+		return
+	}
+	if !visible {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp could not be found")
+		return
+	}
 
-	chirp := Chirp{
-		ID:        dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		Body:      dbChirp.Body,
-		UserID:    dbChirp.UserID,
+	chirps, err := cfg.chirpsFromDB(r.Context(), []database.Chirp{dbChirp})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the Chirp's author")
+		return
 	}
-	utils.RespondWithJSON(w, http.StatusOK, chirp)
+	if err := cfg.addViewerVotes(r.Context(), chirps, viewerID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the Chirp")
+		return
+	}
+	cfg.recordViews(viewerID, []database.Chirp{dbChirp})
+	utils.RespondWithJSON(w, http.StatusOK, chirps[0])
 }
 
 func (cfg *apiConfig) handlerGetAllChirps(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	viewerID, err := cfg.viewer(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+	hidden, err := cfg.hiddenUsers(r.Context(), viewerID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get Chirps")
+		return
+	}
+
 	sortParam := r.URL.Query().Get("sort") 
 	authorIDParam := r.URL.Query().Get("author_id")
-	var chirps []Chirp 
-	if authorIDParam == "" {
+	var dbChirps []database.Chirp
+	if sortParam == "top" || sortParam == "trending" {
+		// Ranked feeds come from the scores computed by runChirpScoring.
+		var authorID uuid.NullUUID
+		if authorIDParam != "" {
+			authorID.UUID, err = uuid.Parse(authorIDParam)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Couldn't parse author id")
+				return
+			}
+			authorID.Valid = true
+		}
 
-		dbChirps, err := cfg.dbQueries.GetAllChirps(r.Context())
+		dbChirps, err = cfg.rankedChirps(r.Context(), sortParam, authorID)
 		if err != nil {
 			utils.RespondWithError(w, http.StatusInternalServerError, "Could not get Chirps")
 			return
 		}
-
-		chirps = make([]Chirp, 0, len(dbChirps))
-		for _, dbChirp := range dbChirps {
-			chirps = append(chirps, Chirp{
-				ID:        dbChirp.ID,
-				CreatedAt: dbChirp.CreatedAt,
-				UpdatedAt: dbChirp.UpdatedAt,
-				Body:      dbChirp.Body,
-				UserID:    dbChirp.UserID,
-			})
+	} else if authorIDParam == "" {
+		dbChirps, err = cfg.dbQueries.GetAllChirps(r.Context())
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not get Chirps")
+			return
 		}
 	} else {
 		authorID, err := uuid.Parse(authorIDParam)
@@ -119,22 +380,30 @@ func (cfg *apiConfig) handlerGetAllChirps(w http.ResponseWriter, r *http.Request
 			return
 		}
 
-		dbChirps, err := cfg.dbQueries.GetAuthorChirps(r.Context(), authorID)
+		dbChirps, err = cfg.dbQueries.GetAuthorChirps(r.Context(), authorID)
 		if err != nil {
 			utils.RespondWithError(w, http.StatusInternalServerError, "Couldn't get the author's chirps")
 			return
 		}
-		chirps = make([]Chirp, 0, len(dbChirps))
-		for _, dbChirp := range dbChirps {
-			chirps = append(chirps, Chirp{
-				ID:        dbChirp.ID,
-				CreatedAt: dbChirp.CreatedAt,
-				UpdatedAt: dbChirp.UpdatedAt,
-				Body:      dbChirp.Body,
-				UserID:    dbChirp.UserID,
-			})
-		}
 	}
+
+	// Authenticated viewers don't see chirps by users they blocked or
+	// muted, or who blocked them. Chirps hidden by moderators are only
+	// shown to their authors.
+	dbChirps = slices.DeleteFunc(dbChirps, func(dbChirp database.Chirp) bool {
+		return slices.Contains(hidden, dbChirp.UserID) || !chirpVisible(dbChirp, viewerID)
+	})
+
+	chirps, err := cfg.chirpsFromDB(r.Context(), dbChirps)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the Chirps' authors")
+		return
+	}
+	if err := cfg.addViewerVotes(r.Context(), chirps, viewerID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get Chirps")
+		return
+	}
+	cfg.recordViews(viewerID, dbChirps)
 	
 	if sortParam == "asc" {
 		sort.Slice(chirps, func(i, j int) bool {
@@ -153,45 +422,199 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, r *http.Request)
 
 	w.Header().Set("Content-Type", "application/json")
 	var params struct {
-		Body string `json:"body"`
+		Body      string      `json:"body"`
+		MediaIDs  []uuid.UUID `json:"media_ids"`
+		InReplyTo *uuid.UUID  `json:"in_reply_to"`
+		PublishAt *time.Time  `json:"publish_at"`
+		Poll      *pollParams `json:"poll"`
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	tokenUUID, err := cfg.authenticate(r, scopeChirpsWrite)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess a login token")
+		utils.RespondWithError(w, http.StatusUnauthorized, "401 Unauthorized")
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithJSON(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
 
-	tokenUUID, err := auth.ValidateJWT(token, cfg.secret)
+	ent, err := cfg.userEntitlements(r.Context(), tokenUUID)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusUnauthorized, "401 Unauthorized")
 		return
 	}
+	if !cfg.allowWrite(w, tokenUUID, ent) {
+		return
+	}
 
-	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		utils.RespondWithJSON(w, http.StatusBadRequest, "Invalid JSON")
+	if chirpLength(params.Body) > ent.MaxChirpLength {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Chirp is too long, the limit is %d characters", ent.MaxChirpLength))
 		return
 	}
 
-	if len(params.Body) > 140 {
-		utils.RespondWithError(w, http.StatusBadRequest, "Chirp is too long")
+	if len(params.MediaIDs) > maxChirpMedia {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("A chirp can have at most %d media attachments", maxChirpMedia))
+		return
+	}
+
+	var scheduledFor sql.NullTime
+	if params.PublishAt != nil {
+		if !ent.ScheduleChirps {
+			utils.RespondWithError(w, http.StatusForbidden, "Scheduling chirps requires Chirpy Red")
+			return
+		}
+		if !params.PublishAt.After(time.Now()) || params.PublishAt.After(time.Now().Add(maxScheduleAhead)) {
+			utils.RespondWithError(w, http.StatusBadRequest, "publish_at must be in the future and within a year")
+			return
+		}
+		scheduledFor = sql.NullTime{Time: params.PublishAt.UTC(), Valid: true}
+	}
+
+	var pollOptions []string
+	var pollClosesAt time.Time
+	if params.Poll != nil {
+		opensAt := time.Now().UTC()
+		if scheduledFor.Valid {
+			opensAt = scheduledFor.Time
+		}
+		pollOptions, pollClosesAt, err = validatePoll(params.Poll, opensAt)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		for i, option := range pollOptions {
+			pollOptions[i] = cleanChirp(option)
+		}
+	}
+
+	inReplyTo, ok := cfg.replyTarget(w, r, tokenUUID, params.InReplyTo)
+	if !ok {
 		return
 	}
 
 	cleanChirp := cleanChirp(params.Body)
 
-	chirp, err := cfg.dbQueries.CreateChirps(r.Context(), database.CreateChirpsParams{
-		Body:   cleanChirp,
-		UserID: tokenUUID,
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Could not create the chirp: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	dbChirp, err := qtx.CreateChirps(r.Context(), database.CreateChirpsParams{
+		Body:         cleanChirp,
+		UserID:       tokenUUID,
+		InReplyTo:    inReplyTo,
+		ScheduledFor: scheduledFor,
 	})
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Could not create the chirp: %s", err))
 		return
 	}
 
+	for i, mediaID := range params.MediaIDs {
+		attached, err := qtx.AttachMediaFile(r.Context(), database.AttachMediaFileParams{
+			ChirpID:  uuid.NullUUID{UUID: dbChirp.ID, Valid: true},
+			Position: int32(i),
+			ID:       mediaID,
+			UserID:   tokenUUID,
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not attach media")
+			return
+		}
+		if attached == 0 {
+			utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Media %s could not be found or is already attached", mediaID))
+			return
+		}
+	}
+
+	dbChirp, err = cfg.storeLinks(r.Context(), qtx, dbChirp)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Could not create the chirp: %s", err))
+		return
+	}
+
+	if pollOptions != nil {
+		_, err = qtx.CreatePoll(r.Context(), database.CreatePollParams{
+			ChirpID:  dbChirp.ID,
+			ClosesAt: pollClosesAt,
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not create the poll")
+			return
+		}
+		err = qtx.CreatePollOptions(r.Context(), database.CreatePollOptionsParams{
+			ChirpID: dbChirp.ID,
+			Options: pollOptions,
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not create the poll")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Could not create the chirp: %s", err))
+		return
+	}
+
+	chirp, err := cfg.chirpFromDB(r.Context(), dbChirp)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the Chirp's author")
+		return
+	}
+	// Scheduled chirps are announced by the publisher once they are due.
+	if !scheduledFor.Valid {
+		cfg.publishEvent(r.Context(), eventChirpCreated, tokenUUID, chirp)
+		cfg.notifyChirp(r.Context(), dbChirp)
+	}
+
 	utils.RespondWithJSON(w, http.StatusCreated, chirp)
 }
 
+// replyTarget checks that the user may reply to the chirp with the given ID.
+// A nil ID means the chirp isn't a reply. When the reply isn't allowed it
+// responds with an error and returns false.
+func (cfg *apiConfig) replyTarget(w http.ResponseWriter, r *http.Request, userID uuid.UUID, chirpID *uuid.UUID) (uuid.NullUUID, bool) {
+	if chirpID == nil {
+		return uuid.NullUUID{}, true
+	}
+
+	parent, err := cfg.dbQueries.GetChirp(r.Context(), *chirpID)
+	if err != nil || !chirpVisible(parent, userID) {
+		utils.RespondWithError(w, http.StatusBadRequest, "The chirp being replied to could not be found")
+		return uuid.NullUUID{}, false
+	}
+	blocked, err := cfg.isBlocked(r.Context(), userID, parent.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create the chirp")
+		return uuid.NullUUID{}, false
+	}
+	if blocked {
+		utils.RespondWithError(w, http.StatusForbidden, "Can't reply to a blocked user")
+		return uuid.NullUUID{}, false
+	}
+	return uuid.NullUUID{UUID: *chirpID, Valid: true}, true
+}
+
+// announceChirps tells live feeds, webhooks and mentioned users about newly
+// published chirps and returns them as API responses.
+func (cfg *apiConfig) announceChirps(ctx context.Context, dbChirps []database.Chirp) ([]Chirp, error) {
+	chirps, err := cfg.chirpsFromDB(ctx, dbChirps)
+	if err != nil {
+		return nil, err
+	}
+	for i, chirp := range chirps {
+		cfg.publishEvent(ctx, eventChirpCreated, chirp.UserID, chirp)
+		cfg.notifyChirp(ctx, dbChirps[i])
+	}
+	return chirps, nil
+}
+
 func cleanChirp(chirp string) string {
 	badwords := map[string]bool{
 		"kerfuffle": true,
@@ -0,0 +1,585 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/auth"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+const (
+	maxConversationMembers = 8
+	maxMessageLength       = 1000
+	defaultMessagesLimit   = 50
+	maxMessagesLimit       = 100
+)
+
+// Events delivered to the other members of a conversation.
+const (
+	eventMessageCreated   = "message.created"
+	eventConversationRead = "conversation.read"
+)
+
+type Message struct {
+	ID             uuid.UUID   `json:"id"`
+	CreatedAt      time.Time   `json:"created_at"`
+	ConversationID uuid.UUID   `json:"conversation_id"`
+	SenderID       uuid.UUID   `json:"sender_id"`
+	Body           string      `json:"body"`
+	ReadBy         []uuid.UUID `json:"read_by"`
+}
+
+// messageFromDB converts a message, working out which of the other members
+// have read it from how far each has read the conversation.
+func messageFromDB(dbMessage database.Message, dbMembers []database.ConversationMember) Message {
+	message := Message{
+		ID:             dbMessage.ID,
+		CreatedAt:      dbMessage.CreatedAt,
+		ConversationID: dbMessage.ConversationID,
+		SenderID:       dbMessage.SenderID,
+		Body:           dbMessage.Body,
+		ReadBy:         []uuid.UUID{},
+	}
+	for _, dbMember := range dbMembers {
+		if dbMember.UserID == dbMessage.SenderID || dbMember.ConversationID != dbMessage.ConversationID {
+			continue
+		}
+		if dbMember.LastReadAt.Valid && !dbMember.LastReadAt.Time.Before(dbMessage.CreatedAt) {
+			message.ReadBy = append(message.ReadBy, dbMember.UserID)
+		}
+	}
+	return message
+}
+
+type ConversationMember struct {
+	User       *Author    `json:"user"`
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type Conversation struct {
+	ID          uuid.UUID            `json:"id"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	Members     []ConversationMember `json:"members"`
+	LastMessage *Message             `json:"last_message"`
+	UnreadCount int64                `json:"unread_count"`
+}
+
+// conversationsFromDB converts conversations into API responses as seen by
+// userID, embedding their members, last message and unread count.
+func (cfg *apiConfig) conversationsFromDB(ctx context.Context, userID uuid.UUID, dbConversations []database.Conversation) ([]Conversation, error) {
+	ids := make([]uuid.UUID, 0, len(dbConversations))
+	for _, dbConversation := range dbConversations {
+		ids = append(ids, dbConversation.ID)
+	}
+
+	dbMembers, err := cfg.dbQueries.GetConversationMembers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	var memberIDs []uuid.UUID
+	for _, dbMember := range dbMembers {
+		if !slices.Contains(memberIDs, dbMember.UserID) {
+			memberIDs = append(memberIDs, dbMember.UserID)
+		}
+	}
+	users, err := cfg.authorsByID(ctx, memberIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	dbLastMessages, err := cfg.dbQueries.GetLastMessages(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	lastMessages := make(map[uuid.UUID]Message, len(dbLastMessages))
+	for _, dbMessage := range dbLastMessages {
+		lastMessages[dbMessage.ConversationID] = messageFromDB(dbMessage, dbMembers)
+	}
+
+	dbUnread, err := cfg.dbQueries.GetUnreadMessageCounts(ctx, database.GetUnreadMessageCountsParams{
+		UserID:          userID,
+		ConversationIds: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+	unread := make(map[uuid.UUID]int64, len(dbUnread))
+	for _, row := range dbUnread {
+		unread[row.ConversationID] = row.UnreadCount
+	}
+
+	conversations := make([]Conversation, 0, len(dbConversations))
+	for _, dbConversation := range dbConversations {
+		conversation := Conversation{
+			ID:          dbConversation.ID,
+			CreatedAt:   dbConversation.CreatedAt,
+			UpdatedAt:   dbConversation.UpdatedAt,
+			Members:     []ConversationMember{},
+			UnreadCount: unread[dbConversation.ID],
+		}
+		for _, dbMember := range dbMembers {
+			if dbMember.ConversationID == dbConversation.ID {
+				conversation.Members = append(conversation.Members, ConversationMember{
+					User:       users[dbMember.UserID],
+					LastReadAt: nullTimePtr(dbMember.LastReadAt),
+				})
+			}
+		}
+		if message, ok := lastMessages[dbConversation.ID]; ok {
+			conversation.LastMessage = &message
+		}
+		conversations = append(conversations, conversation)
+	}
+	return conversations, nil
+}
+
+// parsePageParams reads the ?before cursor and ?limit of a paginated list.
+func parsePageParams(r *http.Request, defaultLimit, maxLimit int) (sql.NullTime, int32, error) {
+	query := r.URL.Query()
+
+	limit := defaultLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := fmt.Sscan(value, &limit)
+		if err != nil || n != 1 || limit < 1 || limit > maxLimit {
+			return sql.NullTime{}, 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+	}
+
+	var before sql.NullTime
+	if value := query.Get("before"); value != "" {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return sql.NullTime{}, 0, errors.New("before must be an RFC 3339 timestamp")
+		}
+		before = sql.NullTime{Time: t, Valid: true}
+	}
+	return before, int32(limit), nil
+}
+
+// conversationForMember returns the conversation in the request path if
+// userID is one of its members. Others get a 404 so conversations can't be
+// probed for.
+func (cfg *apiConfig) conversationForMember(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.Conversation, bool) {
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return database.Conversation{}, false
+	}
+
+	isMember, err := cfg.dbQueries.IsConversationMember(r.Context(), database.IsConversationMemberParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the conversation")
+		return database.Conversation{}, false
+	}
+	if !isMember {
+		utils.RespondWithError(w, http.StatusNotFound, "Conversation could not be found")
+		return database.Conversation{}, false
+	}
+
+	dbConversation, err := cfg.dbQueries.GetConversation(r.Context(), conversationID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Conversation could not be found")
+		return database.Conversation{}, false
+	}
+	return dbConversation, true
+}
+
+func (cfg *apiConfig) handlerCreateConversation(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	var params struct {
+		UserIDs []uuid.UUID `json:"user_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	var others []uuid.UUID
+	for _, id := range params.UserIDs {
+		if id != userID && !slices.Contains(others, id) {
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 || len(others) > maxConversationMembers-1 {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("A conversation needs between 1 and %d other users", maxConversationMembers-1))
+		return
+	}
+
+	users, err := cfg.authorsByID(r.Context(), others)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create the conversation")
+		return
+	}
+	if len(users) != len(others) {
+		utils.RespondWithError(w, http.StatusNotFound, "User could not be found")
+		return
+	}
+
+	blocked, err := cfg.dbQueries.HasBlockBetween(r.Context(), database.HasBlockBetweenParams{
+		UserID:   userID,
+		OtherIds: others,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create the conversation")
+		return
+	}
+	if blocked {
+		utils.RespondWithError(w, http.StatusForbidden, "Can't start a conversation with a blocked user")
+		return
+	}
+
+	// There is only ever one one-to-one conversation between two users.
+	if len(others) == 1 {
+		existingID, err := cfg.dbQueries.FindDirectConversation(r.Context(), database.FindDirectConversationParams{
+			UserID:  userID,
+			OtherID: others[0],
+		})
+		if err == nil {
+			dbConversation, err := cfg.dbQueries.GetConversation(r.Context(), existingID)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the conversation")
+				return
+			}
+			conversations, err := cfg.conversationsFromDB(r.Context(), userID, []database.Conversation{dbConversation})
+			if err != nil {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the conversation")
+				return
+			}
+			utils.RespondWithJSON(w, http.StatusOK, conversations[0])
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not create the conversation")
+			return
+		}
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create the conversation")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	dbConversation, err := qtx.CreateConversation(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create the conversation")
+		return
+	}
+	for _, memberID := range append([]uuid.UUID{userID}, others...) {
+		err := qtx.AddConversationMember(r.Context(), database.AddConversationMemberParams{
+			ConversationID: dbConversation.ID,
+			UserID:         memberID,
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not create the conversation")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create the conversation")
+		return
+	}
+
+	conversations, err := cfg.conversationsFromDB(r.Context(), userID, []database.Conversation{dbConversation})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the conversation")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, conversations[0])
+}
+
+func (cfg *apiConfig) handlerGetConversations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	before, limit, err := parsePageParams(r, defaultMessagesLimit, maxMessagesLimit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbConversations, err := cfg.dbQueries.GetUserConversations(r.Context(), database.GetUserConversationsParams{
+		UserID:     userID,
+		Before:     before,
+		MaxResults: limit,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get conversations")
+		return
+	}
+	conversations, err := cfg.conversationsFromDB(r.Context(), userID, dbConversations)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get conversations")
+		return
+	}
+
+	response := struct {
+		Conversations []Conversation `json:"conversations"`
+		NextBefore    *time.Time     `json:"next_before"`
+	}{
+		Conversations: conversations,
+	}
+	if len(conversations) == int(limit) {
+		response.NextBefore = &conversations[len(conversations)-1].UpdatedAt
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerGetConversation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbConversation, ok := cfg.conversationForMember(w, r, userID)
+	if !ok {
+		return
+	}
+
+	conversations, err := cfg.conversationsFromDB(r.Context(), userID, []database.Conversation{dbConversation})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the conversation")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, conversations[0])
+}
+
+func (cfg *apiConfig) handlerGetMessages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbConversation, ok := cfg.conversationForMember(w, r, userID)
+	if !ok {
+		return
+	}
+
+	before, limit, err := parsePageParams(r, defaultMessagesLimit, maxMessagesLimit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbMessages, err := cfg.dbQueries.GetMessages(r.Context(), database.GetMessagesParams{
+		ConversationID: dbConversation.ID,
+		Before:         before,
+		MaxResults:     limit,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get messages")
+		return
+	}
+	dbMembers, err := cfg.dbQueries.GetConversationMembers(r.Context(), []uuid.UUID{dbConversation.ID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get messages")
+		return
+	}
+
+	messages := make([]Message, 0, len(dbMessages))
+	for _, dbMessage := range dbMessages {
+		messages = append(messages, messageFromDB(dbMessage, dbMembers))
+	}
+
+	response := struct {
+		Messages   []Message  `json:"messages"`
+		NextBefore *time.Time `json:"next_before"`
+	}{
+		Messages: messages,
+	}
+	if len(messages) == int(limit) {
+		response.NextBefore = &messages[len(messages)-1].CreatedAt
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerSendMessage(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbConversation, ok := cfg.conversationForMember(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var params struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if params.Body == "" || len(params.Body) > maxMessageLength {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Message must be between 1 and %d characters", maxMessageLength))
+		return
+	}
+
+	dbMembers, err := cfg.dbQueries.GetConversationMembers(r.Context(), []uuid.UUID{dbConversation.ID})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not send the message")
+		return
+	}
+	var others []uuid.UUID
+	for _, dbMember := range dbMembers {
+		if dbMember.UserID != userID {
+			others = append(others, dbMember.UserID)
+		}
+	}
+
+	// Blocks made after a conversation started stop it too.
+	blocked, err := cfg.dbQueries.HasBlockBetween(r.Context(), database.HasBlockBetweenParams{
+		UserID:   userID,
+		OtherIds: others,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not send the message")
+		return
+	}
+	if blocked {
+		utils.RespondWithError(w, http.StatusForbidden, "Can't message a blocked user")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not send the message")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	dbMessage, err := qtx.CreateMessage(r.Context(), database.CreateMessageParams{
+		ConversationID: dbConversation.ID,
+		SenderID:       userID,
+		Body:           params.Body,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not send the message")
+		return
+	}
+	if err := qtx.TouchConversation(r.Context(), dbConversation.ID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not send the message")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not send the message")
+		return
+	}
+
+	message := messageFromDB(dbMessage, dbMembers)
+	for _, memberID := range others {
+		cfg.publishEvent(r.Context(), eventMessageCreated, memberID, message)
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, message)
+}
+
+func (cfg *apiConfig) handlerMarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbConversation, ok := cfg.conversationForMember(w, r, userID)
+	if !ok {
+		return
+	}
+
+	err = cfg.dbQueries.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: dbConversation.ID,
+		UserID:         userID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not mark the conversation as read")
+		return
+	}
+
+	// Let the other members update their read receipts.
+	dbMembers, err := cfg.dbQueries.GetConversationMembers(r.Context(), []uuid.UUID{dbConversation.ID})
+	if err == nil {
+		receipt := map[string]any{
+			"conversation_id": dbConversation.ID,
+			"user_id":         userID,
+			"read_at":         time.Now().UTC(),
+		}
+		for _, dbMember := range dbMembers {
+			if dbMember.UserID != userID {
+				cfg.publishEvent(r.Context(), eventConversationRead, dbMember.UserID, receipt)
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
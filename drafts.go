@@ -0,0 +1,266 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+// Drafts may hold parts that are too long for the user's plan so they can
+// be shortened later. Nothing any plan couldn't publish is accepted.
+var maxDraftPartLength = redEntitlements.MaxChirpLength
+
+type Draft struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Parts     []string  `json:"parts"`
+}
+
+func draftFromDB(dbDraft database.Draft) Draft {
+	return Draft{
+		ID:        dbDraft.ID,
+		CreatedAt: dbDraft.CreatedAt,
+		UpdatedAt: dbDraft.UpdatedAt,
+		Parts:     dbDraft.Parts,
+	}
+}
+
+// decodeDraftParts reads and validates the parts of a draft from a request.
+func decodeDraftParts(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	var params struct {
+		Parts []string `json:"parts"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return nil, false
+	}
+	if err := validateThreadParts(params.Parts, maxDraftPartLength); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return params.Parts, true
+}
+
+func (cfg *apiConfig) handlerCreateDraft(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	parts, ok := decodeDraftParts(w, r)
+	if !ok {
+		return
+	}
+
+	dbDraft, err := cfg.dbQueries.CreateDraft(r.Context(), database.CreateDraftParams{
+		UserID: userID,
+		Parts:  parts,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not save the draft")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, draftFromDB(dbDraft))
+}
+
+func (cfg *apiConfig) handlerGetDrafts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsRead)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbDrafts, err := cfg.dbQueries.GetDrafts(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get drafts")
+		return
+	}
+
+	drafts := make([]Draft, 0, len(dbDrafts))
+	for _, dbDraft := range dbDrafts {
+		drafts = append(drafts, draftFromDB(dbDraft))
+	}
+	utils.RespondWithJSON(w, http.StatusOK, drafts)
+}
+
+func (cfg *apiConfig) handlerGetDraft(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsRead)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid draft ID")
+		return
+	}
+
+	dbDraft, err := cfg.dbQueries.GetDraft(r.Context(), database.GetDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Draft could not be found")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, draftFromDB(dbDraft))
+}
+
+func (cfg *apiConfig) handlerUpdateDraft(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid draft ID")
+		return
+	}
+
+	parts, ok := decodeDraftParts(w, r)
+	if !ok {
+		return
+	}
+
+	dbDraft, err := cfg.dbQueries.UpdateDraft(r.Context(), database.UpdateDraftParams{
+		ID:     draftID,
+		UserID: userID,
+		Parts:  parts,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "Draft could not be found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not save the draft")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, draftFromDB(dbDraft))
+}
+
+func (cfg *apiConfig) handlerDeleteDraft(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid draft ID")
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not delete the draft")
+		return
+	}
+	if deleted == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Draft could not be found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerPublishDraft publishes a draft as a chirp, or as a thread when it
+// has several parts, and deletes the draft.
+func (cfg *apiConfig) handlerPublishDraft(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid draft ID")
+		return
+	}
+
+	ent, err := cfg.userEntitlements(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+	if !cfg.allowWrite(w, userID, ent) {
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not publish the draft")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	dbDraft, err := qtx.GetDraft(r.Context(), database.GetDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Draft could not be found")
+		return
+	}
+	if err := validateThreadParts(dbDraft.Parts, ent.MaxChirpLength); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbChirps, err := cfg.createThread(r.Context(), qtx, userID, dbDraft.Parts, uuid.NullUUID{})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not publish the draft")
+		return
+	}
+	// Deleting the draft in the same transaction stops it from being
+	// published twice by concurrent requests.
+	deleted, err := qtx.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil || deleted == 0 {
+		utils.RespondWithError(w, http.StatusConflict, "Draft has already been published")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not publish the draft")
+		return
+	}
+
+	chirps, err := cfg.announceChirps(r.Context(), dbChirps)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the Chirps' authors")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, chirps)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestPublishDraftCreatesThreadOnce(t *testing.T) {
+	cfg := newTestConfig(t)
+	_, token := createTestUser(t, cfg)
+
+	rec := serveTestRecorder(cfg.handlerCreateDraft, http.MethodPost, "/api/drafts", "/api/drafts", token, `{"parts":["one","two"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	var draft Draft
+	if err := json.NewDecoder(rec.Body).Decode(&draft); err != nil {
+		t.Fatal(err)
+	}
+
+	path := "/api/drafts/" + draft.ID.String() + "/publish"
+	rec = serveTestRecorder(cfg.handlerPublishDraft, http.MethodPost, "/api/drafts/{draftID}/publish", path, token, "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	var chirps []Chirp
+	if err := json.NewDecoder(rec.Body).Decode(&chirps); err != nil {
+		t.Fatal(err)
+	}
+	if len(chirps) != 2 || chirps[1].InReplyTo == nil || *chirps[1].InReplyTo != chirps[0].ID {
+		t.Errorf("expected a thread of 2 chirps, got %+v", chirps)
+	}
+
+	// The draft is gone once published.
+	if status := serveTestRequest(cfg.handlerPublishDraft, http.MethodPost, "/api/drafts/{draftID}/publish", path, token, ""); status != http.StatusNotFound {
+		t.Errorf("expected 404 when publishing again, got %d", status)
+	}
+	rec = serveTestRecorder(cfg.handlerGetDrafts, http.MethodGet, "/api/drafts", "/api/drafts", token, "")
+	var drafts []Draft
+	if err := json.NewDecoder(rec.Body).Decode(&drafts); err != nil {
+		t.Fatal(err)
+	}
+	if len(drafts) != 0 {
+		t.Errorf("expected no drafts, got %d", len(drafts))
+	}
+}
+
+func TestDraftsArePrivate(t *testing.T) {
+	cfg := newTestConfig(t)
+	_, token := createTestUser(t, cfg)
+	_, otherToken := createTestUser(t, cfg)
+
+	rec := serveTestRecorder(cfg.handlerCreateDraft, http.MethodPost, "/api/drafts", "/api/drafts", token, `{"parts":["secret"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	var draft Draft
+	if err := json.NewDecoder(rec.Body).Decode(&draft); err != nil {
+		t.Fatal(err)
+	}
+
+	path := "/api/drafts/" + draft.ID.String()
+	if status := serveTestRequest(cfg.handlerGetDraft, http.MethodGet, "/api/drafts/{draftID}", path, otherToken, ""); status != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's draft, got %d", status)
+	}
+	if status := serveTestRequest(cfg.handlerDeleteDraft, http.MethodDelete, "/api/drafts/{draftID}", path, otherToken, ""); status != http.StatusNotFound {
+		t.Errorf("expected 404 deleting another user's draft, got %d", status)
+	}
+	if status := serveTestRequest(cfg.handlerDeleteDraft, http.MethodDelete, "/api/drafts/{draftID}", path, token, ""); status != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", status)
+	}
+	if status := serveTestRequest(cfg.handlerGetDraft, http.MethodGet, "/api/drafts/{draftID}", path, token, ""); status != http.StatusNotFound {
+		t.Errorf("expected 404 after deleting, got %d", status)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+// Entitlements are the limits and features a user's plan comes with.
+// Handlers check these rather than is_chirpy_red directly.
+type Entitlements struct {
+	MaxChirpLength  int
+	EditChirps      bool
+	ScheduleChirps  bool
+	WritesPerMinute int
+}
+
+var (
+	freeEntitlements = Entitlements{
+		MaxChirpLength:  140,
+		WritesPerMinute: 10,
+	}
+	redEntitlements = Entitlements{
+		MaxChirpLength:  1000,
+		EditChirps:      true,
+		ScheduleChirps:  true,
+		WritesPerMinute: 60,
+	}
+)
+
+func entitlementsFor(isRed bool) Entitlements {
+	if isRed {
+		return redEntitlements
+	}
+	return freeEntitlements
+}
+
+func (cfg *apiConfig) userEntitlements(ctx context.Context, userID uuid.UUID) (Entitlements, error) {
+	dbUser, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return Entitlements{}, err
+	}
+	return entitlementsFor(dbUser.IsChirpyRed), nil
+}
+
+// allowWrite applies the user's write rate limit. When the limit has been
+// reached it responds with 429 and returns false.
+func (cfg *apiConfig) allowWrite(w http.ResponseWriter, userID uuid.UUID, ent Entitlements) bool {
+	ok, retryAfter := cfg.writeLimiter.Allow(userID.String(), ent.WritesPerMinute)
+	if ok {
+		return true
+	}
+	w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(retryAfter.Seconds()))))
+	utils.RespondWithError(w, http.StatusTooManyRequests, "Too many requests, try again later")
+	return false
+}
@@ -3,9 +3,12 @@ module github.com/tsyrdev/chirpy
 go 1.24.2
 
 require (
-	github.com/golang-jwt/jwt/v5 v5.2.2 // indirect
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
-	github.com/lib/pq v1.10.9 // indirect
-	golang.org/x/crypto v0.37.0 // indirect
+	github.com/coder/websocket v1.8.14
+	github.com/golang-jwt/jwt/v5 v5.2.2
+	github.com/google/uuid v1.6.0
+	github.com/joho/godotenv v1.5.1
+	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.37.0
+	golang.org/x/image v0.25.0
+	golang.org/x/net v0.33.0
 )
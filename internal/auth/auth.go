@@ -2,6 +2,7 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -33,6 +34,25 @@ func GetAPIKey(headers http.Header) (string, error) {
 	return apiKey, nil
 }
 
+const APIKeyPrefix = "chirpy_"
+
+// MakeAPIKey returns a new random personal API key. Only its hash
+// should ever be stored.
+func MakeAPIKey() (string, error) {
+	key := make([]byte, 32)
+
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("Error creating an API key: %w", err)
+	}
+
+	return APIKeyPrefix + hex.EncodeToString(key), nil
+}
+
+func HashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
 func MakeRefreshToken() (string, error) {
 	key := make([]byte, 32)
 
@@ -60,11 +80,11 @@ func CheckPasswordHash(hash, password string) error {
 	return nil
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
+func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
 		Issuer:    "chirpy",
 		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(1 * time.Hour)),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
 		Subject:   userID.String(),
 	})
 
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -57,3 +58,35 @@ func TestInvalidSecret(t *testing.T) {
 		t.Error("expected error for invalid secret, got nil")
 	}
 }
+
+func TestMakeAPIKey(t *testing.T) {
+	key, err := MakeAPIKey()
+	if err != nil {
+		t.Fatalf("failed to create API key: %v", err)
+	}
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		t.Errorf("expected API key to start with %q, got %q", APIKeyPrefix, key)
+	}
+
+	other, err := MakeAPIKey()
+	if err != nil {
+		t.Fatalf("failed to create API key: %v", err)
+	}
+	if key == other {
+		t.Error("expected two API keys to differ")
+	}
+}
+
+func TestHashAPIKey(t *testing.T) {
+	key := "chirpy_abc123"
+
+	if HashAPIKey(key) != HashAPIKey(key) {
+		t.Error("expected hashing to be deterministic")
+	}
+	if HashAPIKey(key) == key {
+		t.Error("expected hash to differ from the key")
+	}
+	if HashAPIKey(key) == HashAPIKey("chirpy_abc124") {
+		t.Error("expected different keys to hash differently")
+	}
+}
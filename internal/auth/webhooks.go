@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	WebhookTimestampHeader = "X-Polka-Timestamp"
+	WebhookSignatureHeader = "X-Polka-Signature"
+	webhookSignaturePrefix = "v1="
+)
+
+var (
+	ErrMissingSignature = errors.New("webhook signature headers are missing")
+	ErrStaleTimestamp   = errors.New("webhook timestamp is outside the tolerance window")
+	ErrInvalidSignature = errors.New("webhook signature does not match")
+)
+
+// SignWebhook returns the hex HMAC-SHA256 of "<unix timestamp>.<body>".
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks the timestamp and signature headers of a
+// webhook delivery against every active secret, so keys can be rotated
+// without downtime. The signature header may hold several comma separated
+// "v1=<hex>" values. It returns the matching signature.
+func VerifyWebhookSignature(headers http.Header, body []byte, secrets []string, tolerance time.Duration, now time.Time) (string, error) {
+	timestampHeader := headers.Get(WebhookTimestampHeader)
+	signatureHeader := headers.Get(WebhookSignatureHeader)
+	if timestampHeader == "" || signatureHeader == "" {
+		return "", ErrMissingSignature
+	}
+
+	timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return "", ErrStaleTimestamp
+	}
+	sent := time.Unix(timestamp, 0)
+	if sent.Before(now.Add(-tolerance)) || sent.After(now.Add(tolerance)) {
+		return "", ErrStaleTimestamp
+	}
+
+	for _, candidate := range strings.Split(signatureHeader, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if !strings.HasPrefix(candidate, webhookSignaturePrefix) {
+			continue
+		}
+		signature := candidate[len(webhookSignaturePrefix):]
+		for _, secret := range secrets {
+			if hmac.Equal([]byte(signature), []byte(SignWebhook(secret, timestamp, body))) {
+				return signature, nil
+			}
+		}
+	}
+	return "", ErrInvalidSignature
+}
+
+// CheckStaticKey compares a presented key against every active key in
+// constant time.
+func CheckStaticKey(key string, validKeys []string) bool {
+	match := 0
+	for _, valid := range validKeys {
+		match |= subtle.ConstantTimeCompare([]byte(key), []byte(valid))
+	}
+	return match == 1
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signedHeaders(secret string, timestamp time.Time, body []byte) http.Header {
+	headers := http.Header{}
+	headers.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	headers.Set(WebhookSignatureHeader, "v1="+SignWebhook(secret, timestamp.Unix(), body))
+	return headers
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"event":"user.upgraded"}`)
+	now := time.Now()
+
+	headers := signedHeaders("new-key", now, body)
+	if _, err := VerifyWebhookSignature(headers, body, []string{"old-key", "new-key"}, 5*time.Minute, now); err != nil {
+		t.Errorf("expected signature from a rotated key to verify, got %v", err)
+	}
+
+	if _, err := VerifyWebhookSignature(headers, []byte(`{"event":"user.refunded"}`), []string{"new-key"}, 5*time.Minute, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for a tampered body, got %v", err)
+	}
+
+	if _, err := VerifyWebhookSignature(headers, body, []string{"other-key"}, 5*time.Minute, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for an unknown key, got %v", err)
+	}
+}
+
+func TestVerifyWebhookSignatureStale(t *testing.T) {
+	body := []byte(`{}`)
+	now := time.Now()
+
+	headers := signedHeaders("key", now.Add(-10*time.Minute), body)
+	if _, err := VerifyWebhookSignature(headers, body, []string{"key"}, 5*time.Minute, now); !errors.Is(err, ErrStaleTimestamp) {
+		t.Errorf("expected ErrStaleTimestamp, got %v", err)
+	}
+
+	if _, err := VerifyWebhookSignature(http.Header{}, body, []string{"key"}, 5*time.Minute, now); !errors.Is(err, ErrMissingSignature) {
+		t.Errorf("expected ErrMissingSignature, got %v", err)
+	}
+}
+
+func TestCheckStaticKey(t *testing.T) {
+	keys := []string{"first", "second"}
+	if !CheckStaticKey("second", keys) {
+		t.Error("expected second key to match")
+	}
+	if CheckStaticKey("third", keys) {
+		t.Error("expected unknown key not to match")
+	}
+	if CheckStaticKey("", nil) {
+		t.Error("expected no match without any keys")
+	}
+}
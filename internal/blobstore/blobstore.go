@@ -0,0 +1,18 @@
+// Package blobstore stores uploaded files outside the database.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore is implemented by every storage backend. Keys are slash
+// separated paths such as "media/<id>.jpg".
+type BlobStore interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
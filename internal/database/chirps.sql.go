@@ -7,29 +7,41 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirps = `-- name: CreateChirps :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, scheduled_for)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, hidden_at, scheduled_for, deleted_at, deleted_by
 `
 
 type CreateChirpsParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body         string
+	UserID       uuid.UUID
+	InReplyTo    uuid.NullUUID
+	ScheduledFor sql.NullTime
 }
 
 func (q *Queries) CreateChirps(ctx context.Context, arg CreateChirpsParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirps, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirps,
+		arg.Body,
+		arg.UserID,
+		arg.InReplyTo,
+		arg.ScheduledFor,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,25 +49,42 @@ func (q *Queries) CreateChirps(ctx context.Context, arg CreateChirpsParams) (Chi
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.HiddenAt,
+		&i.ScheduledFor,
+		&i.DeletedAt,
+		&i.DeletedBy,
 	)
 	return i, err
 }
 
 const deleteChirp = `-- name: DeleteChirp :exec
-DELETE FROM chirps
-WHERE id = $1
+UPDATE chirps
+SET deleted_at = NOW(), deleted_by = $2
+WHERE id = $1 AND deleted_at IS NULL
 `
 
-func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteChirp, id)
+type DeleteChirpParams struct {
+	ID        uuid.UUID
+	DeletedBy uuid.NullUUID
+}
+
+// Chirps are only marked as deleted. PurgeDeletedChirps removes them later.
+func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) error {
+	_, err := q.db.ExecContext(ctx, deleteChirp, arg.ID, arg.DeletedBy)
 	return err
 }
 
 const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, hidden_at, scheduled_for, deleted_at, deleted_by
 FROM chirps
+WHERE scheduled_for IS NULL AND deleted_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.banned_at IS NOT NULL
+)
 `
 
+// Scheduled chirps and chirps by banned users are left out.
 func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 	rows, err := q.db.QueryContext(ctx, getAllChirps)
 	if err != nil {
@@ -71,6 +100,11 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.HiddenAt,
+			&i.ScheduledFor,
+			&i.DeletedAt,
+			&i.DeletedBy,
 		); err != nil {
 			return nil, err
 		}
@@ -86,9 +120,13 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getAuthorChirps = `-- name: GetAuthorChirps :many
-SELECT id, created_at, updated_at, body, user_id 
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, hidden_at, scheduled_for, deleted_at, deleted_by 
 FROM chirps 
 WHERE user_id = $1
+AND scheduled_for IS NULL AND deleted_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.banned_at IS NOT NULL
+)
 `
 
 func (q *Queries) GetAuthorChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
@@ -106,6 +144,11 @@ func (q *Queries) GetAuthorChirps(ctx context.Context, userID uuid.UUID) ([]Chir
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.HiddenAt,
+			&i.ScheduledFor,
+			&i.DeletedAt,
+			&i.DeletedBy,
 		); err != nil {
 			return nil, err
 		}
@@ -121,9 +164,9 @@ func (q *Queries) GetAuthorChirps(ctx context.Context, userID uuid.UUID) ([]Chir
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, hidden_at, scheduled_for, deleted_at, deleted_by
 FROM chirps 
-WHERE id = $1
+WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -135,6 +178,306 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.HiddenAt,
+		&i.ScheduledFor,
+		&i.DeletedAt,
+		&i.DeletedBy,
+	)
+	return i, err
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, hidden_at, scheduled_for, deleted_at, deleted_by
+FROM chirps
+WHERE id = ANY($1::UUID[]) AND deleted_at IS NULL
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.HiddenAt,
+			&i.ScheduledFor,
+			&i.DeletedAt,
+			&i.DeletedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDeletedChirps = `-- name: GetDeletedChirps :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, hidden_at, scheduled_for, deleted_at, deleted_by
+FROM chirps
+WHERE user_id = $1 AND deleted_by = $1
+AND deleted_at > $2::TIMESTAMP
+ORDER BY deleted_at DESC
+`
+
+type GetDeletedChirpsParams struct {
+	UserID       uuid.UUID
+	DeletedAfter time.Time
+}
+
+// Chirps the user deleted themselves that can still be restored.
+func (q *Queries) GetDeletedChirps(ctx context.Context, arg GetDeletedChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getDeletedChirps, arg.UserID, arg.DeletedAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.HiddenAt,
+			&i.ScheduledFor,
+			&i.DeletedAt,
+			&i.DeletedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getScheduledChirps = `-- name: GetScheduledChirps :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, hidden_at, scheduled_for, deleted_at, deleted_by
+FROM chirps
+WHERE user_id = $1 AND scheduled_for IS NOT NULL AND deleted_at IS NULL
+ORDER BY scheduled_for
+`
+
+func (q *Queries) GetScheduledChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getScheduledChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.HiddenAt,
+			&i.ScheduledFor,
+			&i.DeletedAt,
+			&i.DeletedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hideChirp = `-- name: HideChirp :execrows
+UPDATE chirps
+SET hidden_at = NOW()
+WHERE id = $1 AND hidden_at IS NULL
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, hideChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const publishDueChirps = `-- name: PublishDueChirps :many
+UPDATE chirps
+SET created_at = NOW(), updated_at = NOW(), scheduled_for = NULL
+WHERE id IN (
+    SELECT id
+    FROM chirps
+    WHERE scheduled_for <= NOW() AND deleted_at IS NULL
+    AND NOT EXISTS (
+        SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.banned_at IS NOT NULL
+    )
+    ORDER BY scheduled_for
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, hidden_at, scheduled_for, deleted_at, deleted_by
+`
+
+// Several instances may run the publisher at once. Rows locked by another
+// instance are skipped, so each chirp is published exactly once.
+// Chirps by banned authors stay scheduled until they are unbanned.
+func (q *Queries) PublishDueChirps(ctx context.Context, maxResults int32) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, publishDueChirps, maxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.HiddenAt,
+			&i.ScheduledFor,
+			&i.DeletedAt,
+			&i.DeletedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE (deleted_by = user_id AND deleted_at < $1::TIMESTAMP)
+OR (deleted_by IS DISTINCT FROM user_id AND deleted_at < $2::TIMESTAMP)
+`
+
+type PurgeDeletedChirpsParams struct {
+	OwnerDeletedBefore     time.Time
+	ModeratorDeletedBefore time.Time
+}
+
+// Chirps removed by moderators are kept longer than those deleted by
+// their owner.
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, arg PurgeDeletedChirpsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, arg.OwnerDeletedBefore, arg.ModeratorDeletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, deleted_by = NULL
+WHERE id = $1 AND user_id = $2 AND deleted_by = $2
+AND deleted_at > $3::TIMESTAMP
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, hidden_at, scheduled_for, deleted_at, deleted_by
+`
+
+type RestoreChirpParams struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	DeletedAfter time.Time
+}
+
+// Only chirps the owner deleted themselves can be restored.
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, arg.ID, arg.UserID, arg.DeletedAfter)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.HiddenAt,
+		&i.ScheduledFor,
+		&i.DeletedAt,
+		&i.DeletedBy,
+	)
+	return i, err
+}
+
+const unhideChirp = `-- name: UnhideChirp :execrows
+UPDATE chirps
+SET hidden_at = NULL
+WHERE id = $1 AND hidden_at IS NOT NULL
+`
+
+func (q *Queries) UnhideChirp(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unhideChirp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, hidden_at, scheduled_for, deleted_at, deleted_by
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.HiddenAt,
+		&i.ScheduledFor,
+		&i.DeletedAt,
+		&i.DeletedBy,
 	)
 	return i, err
 }
@@ -11,12 +11,199 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	KeyPrefix  string
+	KeyHash    string
+	Scopes     []string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type Bookmark struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Body         string
+	UserID       uuid.UUID
+	InReplyTo    uuid.NullUUID
+	HiddenAt     sql.NullTime
+	ScheduledFor sql.NullTime
+	DeletedAt    sql.NullTime
+	DeletedBy    uuid.NullUUID
+}
+
+type ChirpLink struct {
+	ChirpID  uuid.UUID
+	Position int32
+	Url      string
+}
+
+type ChirpScore struct {
+	ChirpID       uuid.UUID
+	ComputedAt    time.Time
+	Engagement    float64
+	TrendingScore float64
+}
+
+type ChirpView struct {
+	ChirpID uuid.UUID
+	Hour    time.Time
+	Views   int64
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	CreatedBy uuid.UUID
+}
+
+type ConversationMember struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+}
+
+type Draft struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	Body      string
 	UserID    uuid.UUID
+	Parts     []string
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Like struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type LinkPreview struct {
+	Url           string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Status        string
+	Attempts      int32
+	NextAttemptAt time.Time
+	Title         string
+	Description   string
+	ImageUrl      string
+	FetchedAt     sql.NullTime
+}
+
+type List struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	OwnerID     uuid.UUID
+	Name        string
+	Description string
+	Visibility  string
+}
+
+type ListMember struct {
+	ListID    uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type MediaFile struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	ChirpID      uuid.NullUUID
+	Position     int32
+	ContentType  string
+	SizeBytes    int32
+	Width        int32
+	Height       int32
+	StorageKey   string
+	ThumbnailKey string
+}
+
+type Message struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+}
+
+type ModerationAction struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	ModeratorID    uuid.NullUUID
+	Action         string
+	ChirpID        uuid.NullUUID
+	UserID         uuid.NullUUID
+	Reason         string
+	SuspendedUntil sql.NullTime
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ActorID   uuid.UUID
+	Type      string
+	ChirpID   uuid.NullUUID
+	ReadAt    sql.NullTime
+}
+
+type NotificationPreference struct {
+	UserID  uuid.UUID
+	Type    string
+	Enabled bool
+}
+
+type Poll struct {
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+	ClosesAt  time.Time
+}
+
+type PollOption struct {
+	ChirpID  uuid.UUID
+	Position int32
+	Text     string
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	Position  int32
+	CreatedAt time.Time
 }
 
 type RefreshToken struct {
@@ -28,6 +215,38 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type Report struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	ChirpID    uuid.UUID
+	ReporterID uuid.UUID
+	Reason     string
+	Details    string
+	Status     string
+	ResolvedAt sql.NullTime
+}
+
+type ShortLink struct {
+	Code      string
+	CreatedAt time.Time
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	Url       string
+	Clicks    int64
+}
+
+type Subscription struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Plan      string
+	Status    string
+	StartedAt time.Time
+	EndsAt    sql.NullTime
+	EndedAt   sql.NullTime
+}
+
 type User struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
@@ -35,4 +254,53 @@ type User struct {
 	Email          string
 	HashedPassword string
 	IsChirpyRed    bool
+	Handle         sql.NullString
+	DisplayName    string
+	Bio            string
+	AvatarUrl      string
+	IsAdmin        bool
+	SuspendedUntil sql.NullTime
+	BannedAt       sql.NullTime
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	EndpointID     uuid.UUID
+	EventType      string
+	Payload        string
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookEndpoint struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Url        string
+	Secret     string
+	EventTypes []string
+}
+
+type WebhookEvent struct {
+	ID            string
+	ReceivedAt    time.Time
+	UpdatedAt     time.Time
+	EventType     string
+	Payload       string
+	Status        string
+	Attempts      int32
+	LastError     sql.NullString
+	LastAttemptAt sql.NullTime
+}
+
+type WebhookSignature struct {
+	Signature string
+	ExpiresAt time.Time
 }
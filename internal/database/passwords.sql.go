@@ -10,7 +10,7 @@ import (
 )
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red 
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, is_admin, suspended_until, banned_at 
 FROM users 
 WHERE email = $1
 `
@@ -25,6 +25,13 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.IsAdmin,
+		&i.SuspendedUntil,
+		&i.BannedAt,
 	)
 	return i, err
 }
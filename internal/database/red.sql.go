@@ -11,6 +11,17 @@ import (
 	"github.com/google/uuid"
 )
 
+const downgradeUser = `-- name: DowngradeUser :exec
+UPDATE users
+SET is_chirpy_red = FALSE
+WHERE id = $1
+`
+
+func (q *Queries) DowngradeUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, downgradeUser, id)
+	return err
+}
+
 const upgradeUser = `-- name: UpgradeUser :exec
 UPDATE users
 SET is_chirpy_red = TRUE
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
@@ -20,7 +21,7 @@ VALUES (
     $1,
     $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, is_admin, suspended_until, banned_at
 `
 
 type CreateUserParams struct {
@@ -38,6 +39,68 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.IsAdmin,
+		&i.SuspendedUntil,
+		&i.BannedAt,
+	)
+	return i, err
+}
+
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUser, id)
+	return err
+}
+
+const getUserAccountStatus = `-- name: GetUserAccountStatus :one
+SELECT banned_at, suspended_until
+FROM users
+WHERE id = $1
+`
+
+type GetUserAccountStatusRow struct {
+	BannedAt       sql.NullTime
+	SuspendedUntil sql.NullTime
+}
+
+func (q *Queries) GetUserAccountStatus(ctx context.Context, id uuid.UUID) (GetUserAccountStatusRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserAccountStatus, id)
+	var i GetUserAccountStatusRow
+	err := row.Scan(&i.BannedAt, &i.SuspendedUntil)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, is_admin, suspended_until, banned_at
+FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.IsAdmin,
+		&i.SuspendedUntil,
+		&i.BannedAt,
 	)
 	return i, err
 }
@@ -51,11 +114,43 @@ func (q *Queries) ResetUsers(ctx context.Context) error {
 	return err
 }
 
+const setUserBan = `-- name: SetUserBan :exec
+UPDATE users
+SET banned_at = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetUserBanParams struct {
+	ID       uuid.UUID
+	BannedAt sql.NullTime
+}
+
+func (q *Queries) SetUserBan(ctx context.Context, arg SetUserBanParams) error {
+	_, err := q.db.ExecContext(ctx, setUserBan, arg.ID, arg.BannedAt)
+	return err
+}
+
+const setUserSuspension = `-- name: SetUserSuspension :exec
+UPDATE users
+SET suspended_until = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetUserSuspensionParams struct {
+	ID             uuid.UUID
+	SuspendedUntil sql.NullTime
+}
+
+func (q *Queries) SetUserSuspension(ctx context.Context, arg SetUserSuspensionParams) error {
+	_, err := q.db.ExecContext(ctx, setUserSuspension, arg.ID, arg.SuspendedUntil)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET email = $2, hashed_password = $3, updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, is_admin, suspended_until, banned_at
 `
 
 type UpdateUserParams struct {
@@ -74,6 +169,13 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.IsAdmin,
+		&i.SuspendedUntil,
+		&i.BannedAt,
 	)
 	return i, err
 }
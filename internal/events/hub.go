@@ -0,0 +1,131 @@
+// Package events is an in-process publish/subscribe hub for real-time
+// updates. Recent events are kept so clients can resume after reconnecting.
+package events
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Event is a published update. IDs increase monotonically so they can be
+// used to resume a stream. Events published through a Relay get their IDs
+// from Postgres, which orders them the same way on every instance.
+type Event struct {
+	ID     int64
+	Type   string
+	UserID uuid.UUID
+	Data   []byte
+}
+
+// Filter selects the events a subscriber receives.
+type Filter func(Event) bool
+
+// Subscription receives published events on C. C is closed when the
+// subscription is closed, including when the hub drops a subscriber that
+// couldn't keep up.
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	filter Filter
+	hub    *Hub
+	closed bool
+}
+
+// Close unsubscribes from the hub.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// Hub fans published events out to subscribers.
+type Hub struct {
+	mu          sync.Mutex
+	lastID      int64 // the highest event ID seen so far
+	history     []Event
+	historySize int
+	bufferSize  int
+	subs        map[*Subscription]struct{}
+}
+
+// NewHub returns a hub that keeps the last historySize events for resuming
+// and buffers up to bufferSize events per subscriber.
+func NewHub(historySize, bufferSize int) *Hub {
+	return &Hub{
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subs:        make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the event the next ID after the highest one seen and
+// delivers it.
+func (h *Hub) Publish(eventType string, userID uuid.UUID, data []byte) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	event := Event{ID: h.lastID + 1, Type: eventType, UserID: userID, Data: data}
+	h.deliver(event)
+	return event
+}
+
+// Deliver delivers an event whose ID was assigned elsewhere.
+func (h *Hub) Deliver(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.deliver(event)
+}
+
+// deliver records the event and sends it to every matching subscriber.
+// Subscribers whose buffer is full are dropped rather than blocking the
+// publisher.
+func (h *Hub) deliver(event Event) {
+	h.lastID = max(h.lastID, event.ID)
+	h.history = append(h.history, event)
+	if len(h.history) > h.historySize {
+		h.history = h.history[len(h.history)-h.historySize:]
+	}
+
+	for sub := range h.subs {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.c <- event:
+		default:
+			h.remove(sub)
+		}
+	}
+}
+
+// Subscribe registers a subscriber. If lastID is not zero, the retained
+// events published after it are returned so that the caller can replay them
+// before reading from the subscription.
+func (h *Hub) Subscribe(filter Filter, lastID int64) (*Subscription, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := make(chan Event, h.bufferSize)
+	sub := &Subscription{C: c, c: c, filter: filter, hub: h}
+	h.subs[sub] = struct{}{}
+
+	var missed []Event
+	if lastID != 0 {
+		for _, event := range h.history {
+			if event.ID > lastID && (filter == nil || filter(event)) {
+				missed = append(missed, event)
+			}
+		}
+	}
+	return sub, missed
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(h.subs, sub)
+	close(sub.c)
+}
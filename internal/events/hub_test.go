@@ -0,0 +1,82 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPublishFilter(t *testing.T) {
+	hub := NewHub(10, 10)
+	alice, bob := uuid.New(), uuid.New()
+
+	sub, _ := hub.Subscribe(func(e Event) bool { return e.UserID == alice }, 0)
+	defer sub.Close()
+
+	hub.Publish("chirp.created", bob, nil)
+	want := hub.Publish("chirp.created", alice, []byte(`{}`))
+
+	select {
+	case got := <-sub.C:
+		if got.ID != want.ID {
+			t.Errorf("expected event %d, got %d", want.ID, got.ID)
+		}
+	default:
+		t.Fatal("expected a matching event")
+	}
+	select {
+	case got := <-sub.C:
+		t.Errorf("expected no more events, got %+v", got)
+	default:
+	}
+}
+
+func TestSubscribeResume(t *testing.T) {
+	hub := NewHub(2, 10)
+	hub.Publish("chirp.created", uuid.New(), nil)
+	first := hub.Publish("chirp.created", uuid.New(), nil)
+	second := hub.Publish("chirp.created", uuid.New(), nil)
+	third := hub.Publish("chirp.deleted", uuid.New(), nil)
+
+	sub, missed := hub.Subscribe(nil, first.ID)
+	defer sub.Close()
+	if len(missed) != 2 || missed[0].ID != second.ID || missed[1].ID != third.ID {
+		t.Errorf("expected to resume with events %d and %d, got %+v", second.ID, third.ID, missed)
+	}
+
+	// Events older than the history are gone, the rest is still replayed.
+	_, missed = hub.Subscribe(nil, first.ID-1)
+	if len(missed) != 2 {
+		t.Errorf("expected the retained events, got %+v", missed)
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	hub := NewHub(10, 1)
+	sub, _ := hub.Subscribe(nil, 0)
+
+	hub.Publish("chirp.created", uuid.New(), nil)
+	hub.Publish("chirp.created", uuid.New(), nil)
+
+	<-sub.C
+	if _, ok := <-sub.C; ok {
+		t.Error("expected the subscription to be closed")
+	}
+	sub.Close()
+}
+
+func TestDeliverKeepsIDsIncreasing(t *testing.T) {
+	hub := NewHub(10, 10)
+	hub.Deliver(Event{ID: 41, Type: "chirp.created", UserID: uuid.New()})
+	hub.Deliver(Event{ID: 40, Type: "chirp.created", UserID: uuid.New()})
+
+	if event := hub.Publish("chirp.created", uuid.New(), nil); event.ID != 42 {
+		t.Errorf("expected the next local event to get ID 42, got %d", event.ID)
+	}
+
+	sub, missed := hub.Subscribe(nil, 40)
+	defer sub.Close()
+	if len(missed) != 2 || missed[0].ID != 41 || missed[1].ID != 42 {
+		t.Errorf("expected to resume with events 41 and 42, got %+v", missed)
+	}
+}
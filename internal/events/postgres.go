@@ -0,0 +1,141 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// notifyChannel is the Postgres channel events are relayed over.
+const notifyChannel = "chirpy_events"
+
+// maxNotifyPayload stays below Postgres' 8000 byte NOTIFY payload limit.
+const maxNotifyPayload = 7900
+
+type notification struct {
+	Origin string          `json:"origin"`
+	ID     int64           `json:"id"`
+	Type   string          `json:"type"`
+	UserID uuid.UUID       `json:"user_id"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	// Stored is set when Data was too large to notify and has to be read
+	// from the event_payloads table.
+	Stored bool `json:"stored,omitempty"`
+}
+
+// Relay publishes events to the local hub and, through Postgres
+// LISTEN/NOTIFY, to the hubs of every other server instance.
+type Relay struct {
+	hub     *Hub
+	db      *sql.DB
+	connStr string
+	origin  string
+}
+
+// NewRelay returns a relay for hub. connStr is used to open the dedicated
+// connection that listens for other instances' events.
+func NewRelay(hub *Hub, db *sql.DB, connStr string) *Relay {
+	return &Relay{
+		hub:     hub,
+		db:      db,
+		connStr: connStr,
+		origin:  uuid.NewString(),
+	}
+}
+
+// Publish delivers an event locally and notifies the other instances. The
+// event ID comes from the event_ids sequence so that IDs are ordered across
+// instances and restarts. Nothing is delivered when Postgres can't be reached,
+// as an ID assigned locally could collide with the sequence's.
+func (r *Relay) Publish(ctx context.Context, eventType string, userID uuid.UUID, data []byte) error {
+	var id int64
+	if err := r.db.QueryRowContext(ctx, "SELECT nextval('event_ids')").Scan(&id); err != nil {
+		return err
+	}
+	r.hub.Deliver(Event{ID: id, Type: eventType, UserID: userID, Data: data})
+
+	msg := notification{
+		Origin: r.origin,
+		ID:     id,
+		Type:   eventType,
+		UserID: userID,
+		Data:   data,
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxNotifyPayload {
+		if err := r.storePayload(ctx, id, data); err != nil {
+			return err
+		}
+		msg.Data = nil
+		msg.Stored = true
+		if payload, err = json.Marshal(msg); err != nil {
+			return err
+		}
+	}
+	_, err = r.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", notifyChannel, string(payload))
+	return err
+}
+
+// storePayload saves the data of an event too large to notify, and removes
+// payloads old enough that every instance has read them.
+func (r *Relay) storePayload(ctx context.Context, id int64, data []byte) error {
+	_, err := r.db.ExecContext(ctx, "INSERT INTO event_payloads (id, created_at, data) VALUES ($1, NOW(), $2)", id, data)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, "DELETE FROM event_payloads WHERE created_at < NOW() - INTERVAL '1 hour'")
+	return err
+}
+
+// Listen publishes events notified by other instances into the local hub.
+// It reconnects on its own and only returns if it can't start listening.
+func (r *Relay) Listen() error {
+	listener := pq.NewListener(r.connStr, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Event relay connection: %v", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(notifyChannel); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case n := <-listener.Notify:
+			// A nil notification means the connection was re-established.
+			if n == nil {
+				continue
+			}
+			var msg notification
+			if err := json.Unmarshal([]byte(n.Extra), &msg); err != nil {
+				log.Printf("Event relay: invalid notification: %v", err)
+				continue
+			}
+			if msg.Origin == r.origin {
+				continue
+			}
+			if msg.Stored {
+				var data []byte
+				if err := r.db.QueryRow("SELECT data FROM event_payloads WHERE id = $1", msg.ID).Scan(&data); err != nil {
+					log.Printf("Event relay: could not load event %d: %v", msg.ID, err)
+					continue
+				}
+				msg.Data = data
+			}
+			r.hub.Deliver(Event{ID: msg.ID, Type: msg.Type, UserID: msg.UserID, Data: msg.Data})
+
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRelayLargePayload(t *testing.T) {
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sender := NewRelay(NewHub(10, 10), db, dbURL)
+	receiverHub := NewHub(10, 10)
+	receiver := NewRelay(receiverHub, db, dbURL)
+	go receiver.Listen()
+	// Give the listener time to start.
+	time.Sleep(500 * time.Millisecond)
+
+	sub, _ := receiverHub.Subscribe(func(Event) bool { return true }, 0)
+	defer sub.Close()
+
+	data := []byte(`"` + string(bytes.Repeat([]byte("a"), 2*maxNotifyPayload)) + `"`)
+	if err := sender.Publish(context.Background(), "chirp.created", uuid.New(), data); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-sub.C:
+		if !bytes.Equal(event.Data, data) {
+			t.Errorf("expected %d bytes of data, got %d", len(data), len(event.Data))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the event didn't reach the other instance")
+	}
+}
@@ -0,0 +1,52 @@
+// Package impressions counts chirp views in memory so that they can be
+// written to the database in batches instead of on every request.
+package impressions
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Counter counts views per chirp between flushes.
+type Counter struct {
+	mu     sync.Mutex
+	counts map[uuid.UUID]int64
+}
+
+// NewCounter returns an empty Counter.
+func NewCounter() *Counter {
+	return &Counter{counts: make(map[uuid.UUID]int64)}
+}
+
+// Add records one view of each of the given chirps.
+func (c *Counter) Add(chirpIDs ...uuid.UUID) {
+	if len(chirpIDs) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range chirpIDs {
+		c.counts[id]++
+	}
+}
+
+// Take returns the views counted since the last call and starts counting
+// from zero.
+func (c *Counter) Take() map[uuid.UUID]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := c.counts
+	c.counts = make(map[uuid.UUID]int64)
+	return counts
+}
+
+// Restore adds counts returned by Take back, for when they couldn't be
+// saved.
+func (c *Counter) Restore(counts map[uuid.UUID]int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, n := range counts {
+		c.counts[id] += n
+	}
+}
@@ -0,0 +1,58 @@
+package impressions
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCounter(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	c := NewCounter()
+	c.Add(a, b)
+	c.Add(a)
+
+	counts := c.Take()
+	if counts[a] != 2 || counts[b] != 1 {
+		t.Errorf("expected 2 views of a and 1 of b, got %v", counts)
+	}
+	if len(c.Take()) != 0 {
+		t.Error("expected Take to reset the counts")
+	}
+
+	c.Add(b)
+	c.Restore(counts)
+	counts = c.Take()
+	if counts[a] != 2 || counts[b] != 2 {
+		t.Errorf("expected restored counts to be added, got %v", counts)
+	}
+}
+
+func TestCounterConcurrent(t *testing.T) {
+	id := uuid.New()
+	c := NewCounter()
+
+	var wg sync.WaitGroup
+	total := int64(0)
+	var mu sync.Mutex
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				c.Add(id)
+			}
+			taken := c.Take()[id]
+			mu.Lock()
+			total += taken
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	total += c.Take()[id]
+
+	if total != 800 {
+		t.Errorf("expected 800 views, got %d", total)
+	}
+}
@@ -0,0 +1,253 @@
+// Package linkpreview fetches OpenGraph metadata for links posted in chirps
+// so they can be shown as previews.
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tsyrdev/chirpy/internal/netguard"
+	"golang.org/x/net/html"
+)
+
+// MaxURLLength is the longest URL a preview is fetched for.
+const MaxURLLength = 2048
+
+const (
+	maxRedirects         = 3
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+)
+
+var (
+	// ErrBlockedAddress is returned for URLs that resolve to private,
+	// loopback or otherwise internal addresses, or use a port other than
+	// 80 or 443.
+	ErrBlockedAddress = netguard.ErrBlockedAddress
+	// ErrNoPreview is returned for pages without any preview metadata.
+	ErrNoPreview = errors.New("linkpreview: page has no preview metadata")
+)
+
+// allowedAddr reports whether a connection to addr may be made: it has to
+// be a public unicast address on the standard HTTP or HTTPS port.
+func allowedAddr(addr netip.AddrPort) bool {
+	if addr.Port() != 80 && addr.Port() != 443 {
+		return false
+	}
+	return netguard.PublicAddr(addr.Addr())
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// FindURLs returns the start and end offsets of every http(s) URL in text.
+// Trailing punctuation is not considered part of a URL.
+func FindURLs(text string) [][2]int {
+	var found [][2]int
+	for _, loc := range urlPattern.FindAllStringIndex(text, -1) {
+		match := strings.TrimRight(text[loc[0]:loc[1]], ".,;:!?)]}'")
+		found = append(found, [2]int{loc[0], loc[0] + len(match)})
+	}
+	return found
+}
+
+// ExtractURLs returns up to max distinct http(s) URLs in text, in the order
+// they appear.
+func ExtractURLs(text string, max int) []string {
+	var urls []string
+	for _, loc := range FindURLs(text) {
+		match := text[loc[0]:loc[1]]
+		if len(match) > MaxURLLength {
+			continue
+		}
+		u, err := url.Parse(match)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		if !slices.Contains(urls, match) {
+			urls = append(urls, match)
+		}
+		if len(urls) == max {
+			break
+		}
+	}
+	return urls
+}
+
+// Preview is the metadata shown for a link.
+type Preview struct {
+	Title       string
+	Description string
+	ImageURL    string
+}
+
+// Fetcher fetches and parses pages for previews.
+type Fetcher struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+// NewFetcher returns a Fetcher that gives up on pages after timeout and
+// reads at most maxBytes of each. Connections are checked after DNS
+// resolution, so redirects and DNS tricks can't reach internal addresses.
+func NewFetcher(timeout time.Duration, maxBytes int64) *Fetcher {
+	return newFetcher(timeout, maxBytes, allowedAddr)
+}
+
+func newFetcher(timeout time.Duration, maxBytes int64, allow func(netip.AddrPort) bool) *Fetcher {
+	dialer := netguard.NewDialer(timeout, allow)
+	transport := &http.Transport{
+		// A proxy would make the connection checks useless.
+		Proxy:                  nil,
+		DialContext:            dialer.DialContext,
+		TLSHandshakeTimeout:    timeout,
+		ResponseHeaderTimeout:  timeout,
+		MaxResponseHeaderBytes: 16 << 10,
+	}
+	return &Fetcher{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return errors.New("linkpreview: too many redirects")
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return ErrBlockedAddress
+				}
+				return nil
+			},
+		},
+		maxBytes: maxBytes,
+	}
+}
+
+// Fetch downloads the page at rawURL and extracts its preview.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Preview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Preview{}, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Preview{}, ErrBlockedAddress
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("User-Agent", "Chirpy-LinkPreview/1.0")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Preview{}, fmt.Errorf("linkpreview: page responded with %s", resp.Status)
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "text/html" && mediaType != "application/xhtml+xml") {
+		return Preview{}, ErrNoPreview
+	}
+
+	preview := parse(io.LimitReader(resp.Body, f.maxBytes), resp.Request.URL)
+	if preview == (Preview{}) {
+		return Preview{}, ErrNoPreview
+	}
+	return preview, nil
+}
+
+// parse reads the head of an HTML page for OpenGraph tags, falling back to
+// the page's title and description. Relative image URLs are resolved
+// against base.
+func parse(r io.Reader, base *url.URL) Preview {
+	var preview, fallback Preview
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return finish(preview, fallback, base)
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" {
+				return finish(preview, fallback, base)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "body":
+				return finish(preview, fallback, base)
+			case "title":
+				if z.Next() == html.TextToken {
+					fallback.Title = string(z.Text())
+				}
+			case "meta":
+				if !hasAttr {
+					continue
+				}
+				attrs := map[string]string{}
+				for {
+					key, value, more := z.TagAttr()
+					attrs[string(key)] = string(value)
+					if !more {
+						break
+					}
+				}
+				content := attrs["content"]
+				switch strings.ToLower(attrs["property"]) {
+				case "og:title":
+					preview.Title = content
+				case "og:description":
+					preview.Description = content
+				case "og:image":
+					preview.ImageURL = content
+				}
+				if strings.ToLower(attrs["name"]) == "description" {
+					fallback.Description = content
+				}
+			}
+		}
+	}
+}
+
+func finish(preview, fallback Preview, base *url.URL) Preview {
+	if preview.Title == "" {
+		preview.Title = fallback.Title
+	}
+	if preview.Description == "" {
+		preview.Description = fallback.Description
+	}
+	preview.Title = clean(preview.Title, maxTitleLength)
+	preview.Description = clean(preview.Description, maxDescriptionLength)
+
+	if preview.ImageURL != "" {
+		image, err := base.Parse(strings.TrimSpace(preview.ImageURL))
+		if err != nil || (image.Scheme != "http" && image.Scheme != "https") || len(image.String()) > MaxURLLength {
+			preview.ImageURL = ""
+		} else {
+			preview.ImageURL = image.String()
+		}
+	}
+	return preview
+}
+
+// clean collapses whitespace and cuts s to at most max bytes without
+// splitting a character.
+func clean(s string, max int) string {
+	s = strings.Join(strings.Fields(strings.ToValidUTF8(s, "")), " ")
+	if len(s) <= max {
+		return s
+	}
+	return strings.ToValidUTF8(s[:max], "")
+}
@@ -0,0 +1,178 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// allowAll lets tests reach httptest servers on the loopback address.
+func allowAll(netip.AddrPort) bool { return true }
+
+func TestExtractURLs(t *testing.T) {
+	text := "Read https://example.com/a, then (http://example.org/b?x=1) and https://example.com/a again. Also https://example.net/c!"
+	got := ExtractURLs(text, 2)
+	want := []string{"https://example.com/a", "http://example.org/b?x=1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if got := ExtractURLs("no links here, just ftp://example.com", 4); len(got) != 0 {
+		t.Errorf("expected no URLs, got %v", got)
+	}
+}
+
+func TestFindURLs(t *testing.T) {
+	text := "see https://example.com/a. and https://example.com/a"
+	got := FindURLs(text)
+	want := [][2]int{{4, 25}, {31, 52}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestAllowedAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34:443", true},
+		{"93.184.216.34:80", true},
+		{"93.184.216.34:8080", false},
+		{"127.0.0.1:80", false},
+		{"10.0.0.5:443", false},
+		{"172.16.3.4:443", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"100.64.0.1:80", false},
+		{"0.0.0.0:80", false},
+		{"[::1]:443", false},
+		{"[fd00::1]:443", false},
+		{"[fe80::1]:443", false},
+		{"[::ffff:127.0.0.1]:443", false},
+		{"[2606:2800:220:1::248]:443", true},
+	}
+	for _, tt := range tests {
+		if got := allowedAddr(netip.MustParseAddrPort(tt.addr)); got != tt.want {
+			t.Errorf("allowedAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<!doctype html><html><head>
+<title>Fallback title</title>
+<meta property="og:title" content="  A   chirp-worthy page ">
+<meta property="og:description" content="What it's about">
+<meta property="og:image" content="/images/card.png">
+</head><body><meta property="og:title" content="ignored"></body></html>`))
+	}))
+	defer page.Close()
+
+	preview, err := newFetcher(time.Second, 64<<10, allowAll).Fetch(context.Background(), page.URL+"/post")
+	if err != nil {
+		t.Fatalf("expected fetch to succeed, got %v", err)
+	}
+	want := Preview{
+		Title:       "A chirp-worthy page",
+		Description: "What it's about",
+		ImageURL:    page.URL + "/images/card.png",
+	}
+	if preview != want {
+		t.Errorf("expected %+v, got %+v", want, preview)
+	}
+}
+
+func TestFetchFallsBackToTitle(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Plain page</title><meta name="description" content="Described"></head></html>`))
+	}))
+	defer page.Close()
+
+	preview, err := newFetcher(time.Second, 64<<10, allowAll).Fetch(context.Background(), page.URL)
+	if err != nil {
+		t.Fatalf("expected fetch to succeed, got %v", err)
+	}
+	if preview.Title != "Plain page" || preview.Description != "Described" || preview.ImageURL != "" {
+		t.Errorf("unexpected preview %+v", preview)
+	}
+}
+
+func TestFetchBlocksInternalAddresses(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("internal server should not be reached")
+	}))
+	defer page.Close()
+
+	_, err := NewFetcher(time.Second, 64<<10).Fetch(context.Background(), page.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("expected ErrBlockedAddress, got %v", err)
+	}
+}
+
+func TestFetchBlocksRedirectsToInternalAddresses(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("internal server should not be reached")
+	}))
+	defer internal.Close()
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	// Only the redirecting server is reachable.
+	allowRedirector := func(addr netip.AddrPort) bool {
+		return strings.HasSuffix(redirector.URL, addr.String())
+	}
+	_, err := newFetcher(time.Second, 64<<10, allowRedirector).Fetch(context.Background(), redirector.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("expected ErrBlockedAddress, got %v", err)
+	}
+}
+
+func TestFetchLimitsSize(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><!--" + strings.Repeat("x", 2048) + `--><meta property="og:title" content="Too far"></head></html>`))
+	}))
+	defer page.Close()
+
+	_, err := newFetcher(time.Second, 1024, allowAll).Fetch(context.Background(), page.URL)
+	if !errors.Is(err, ErrNoPreview) {
+		t.Errorf("expected ErrNoPreview, got %v", err)
+	}
+}
+
+func TestFetchRejectsNonHTML(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte(`<meta property="og:title" content="Binary">`))
+	}))
+	defer page.Close()
+
+	_, err := newFetcher(time.Second, 64<<10, allowAll).Fetch(context.Background(), page.URL)
+	if !errors.Is(err, ErrNoPreview) {
+		t.Errorf("expected ErrNoPreview, got %v", err)
+	}
+}
+
+func TestFetchTimesOut(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer page.Close()
+
+	_, err := newFetcher(50*time.Millisecond, 64<<10, allowAll).Fetch(context.Background(), page.URL)
+	if err == nil {
+		t.Error("expected a slow page to time out")
+	}
+}
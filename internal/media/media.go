@@ -0,0 +1,124 @@
+// Package media validates and normalises uploaded images.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	MaxUploadBytes = 5 << 20
+	ThumbnailSize  = 320
+	// MaxDimension and MaxPixels cap the decoded size of an image. A small
+	// file can declare enormous dimensions, so they are checked before the
+	// pixels are decoded.
+	MaxDimension = 10000
+	MaxPixels    = 25_000_000
+)
+
+var (
+	ErrTooLarge           = errors.New("image is too large")
+	ErrUnsupportedType    = errors.New("only JPEG and PNG images are supported")
+	ErrDimensionsTooLarge = errors.New("image dimensions are too large")
+)
+
+type Image struct {
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+	Thumbnail   []byte
+}
+
+// Process validates an uploaded image and re-encodes it. Re-encoding drops
+// every metadata segment, which strips EXIF data such as GPS coordinates.
+// It also produces a thumbnail no larger than ThumbnailSize on either side.
+func Process(data []byte) (Image, error) {
+	if len(data) > MaxUploadBytes {
+		return Image{}, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return Image{}, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, ErrUnsupportedType
+	}
+	if config.Width > MaxDimension || config.Height > MaxDimension || config.Width*config.Height > MaxPixels {
+		return Image{}, ErrDimensionsTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, ErrUnsupportedType
+	}
+
+	clean, err := encode(src, contentType)
+	if err != nil {
+		return Image{}, err
+	}
+	thumbnail, err := encode(thumbnail(src), contentType)
+	if err != nil {
+		return Image{}, err
+	}
+
+	bounds := src.Bounds()
+	return Image{
+		ContentType: contentType,
+		Data:        clean,
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+		Thumbnail:   thumbnail,
+	}, nil
+}
+
+func encode(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func thumbnail(src image.Image) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= ThumbnailSize && h <= ThumbnailSize {
+		return src
+	}
+
+	if w >= h {
+		h = max(1, h*ThumbnailSize/w)
+		w = ThumbnailSize
+	} else {
+		w = max(1, w*ThumbnailSize/h)
+		h = ThumbnailSize
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	return dst
+}
+
+// Extension returns the file extension used when storing an image.
+func Extension(contentType string) string {
+	if contentType == "image/png" {
+		return ".png"
+	}
+	return ".jpg"
+}
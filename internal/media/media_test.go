@@ -0,0 +1,104 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	return img
+}
+
+func TestProcessPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(800, 400)); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := Process(buf.Bytes())
+	if err != nil {
+		t.Fatalf("failed to process image: %v", err)
+	}
+	if img.ContentType != "image/png" {
+		t.Errorf("expected image/png, got %s", img.ContentType)
+	}
+	if img.Width != 800 || img.Height != 400 {
+		t.Errorf("expected 800x400, got %dx%d", img.Width, img.Height)
+	}
+
+	thumb, err := png.Decode(bytes.NewReader(img.Thumbnail))
+	if err != nil {
+		t.Fatalf("failed to decode thumbnail: %v", err)
+	}
+	if b := thumb.Bounds(); b.Dx() != ThumbnailSize || b.Dy() != ThumbnailSize/2 {
+		t.Errorf("expected %dx%d thumbnail, got %dx%d", ThumbnailSize, ThumbnailSize/2, b.Dx(), b.Dy())
+	}
+}
+
+func TestProcessStripsEXIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(64, 64), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Splice an APP1 Exif segment in right after the SOI marker.
+	payload := append([]byte("Exif\x00\x00"), []byte("GPS 51.5N 0.12W")...)
+	segment := []byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	segment = append(segment, payload...)
+	withExif := append([]byte{}, buf.Bytes()[:2]...)
+	withExif = append(withExif, segment...)
+	withExif = append(withExif, buf.Bytes()[2:]...)
+
+	img, err := Process(withExif)
+	if err != nil {
+		t.Fatalf("failed to process image: %v", err)
+	}
+	if bytes.Contains(img.Data, []byte("Exif")) || bytes.Contains(img.Data, []byte("GPS")) {
+		t.Error("expected EXIF data to be stripped")
+	}
+}
+
+func TestProcessRejectsOtherTypes(t *testing.T) {
+	_, err := Process([]byte("GIF89a not really an image"))
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("expected ErrUnsupportedType, got %v", err)
+	}
+
+	_, err = Process(make([]byte, MaxUploadBytes+1))
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestProcessRejectsHugeDimensions(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(1, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewrite the IHDR chunk, which follows the 8-byte signature, to claim
+	// 50000x50000 pixels and fix up its checksum.
+	data := buf.Bytes()
+	ihdr := data[8+4 : 8+4+4+13]
+	binary.BigEndian.PutUint32(ihdr[4:8], 50000)
+	binary.BigEndian.PutUint32(ihdr[8:12], 50000)
+	binary.BigEndian.PutUint32(data[8+4+4+13:], crc32.ChecksumIEEE(ihdr))
+
+	_, err := Process(data)
+	if !errors.Is(err, ErrDimensionsTooLarge) {
+		t.Errorf("expected ErrDimensionsTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+// Package netguard keeps outgoing requests made on behalf of users, such as
+// link previews and webhook deliveries, away from internal addresses.
+package netguard
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress is returned when a connection to a private, loopback or
+// otherwise internal address is refused.
+var ErrBlockedAddress = errors.New("netguard: address not allowed")
+
+// Address ranges that are neither private nor loopback but still must not
+// be reached from the server.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// PublicAddr reports whether ip is a public unicast address. Loopback,
+// private, link-local (including cloud metadata services) and other
+// special-purpose ranges are not.
+func PublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// BlockedHost reports whether a URL host is obviously internal: localhost
+// or a literal non-public IP address. Names that resolve to internal
+// addresses are caught when connecting by a dialer from NewDialer.
+func BlockedHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	if ip, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil {
+		return !PublicAddr(ip)
+	}
+	return false
+}
+
+// NewDialer returns a dialer that refuses connections allow rejects. The
+// check runs after DNS resolution, on the address actually connected to, so
+// redirects and DNS rebinding can't get around it. Transports using it must
+// not use a proxy.
+func NewDialer(timeout time.Duration, allow func(netip.AddrPort) bool) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !allow(addr) {
+				return ErrBlockedAddress
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,68 @@
+package netguard
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"127.0.0.1", false},
+		{"10.0.0.5", false},
+		{"172.16.3.4", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"2606:2800:220:1::248", true},
+	}
+	for _, tt := range tests {
+		if got := PublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("PublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestBlockedHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"example.com", false},
+		{"93.184.216.34", false},
+		{"localhost", true},
+		{"LocalHost.", true},
+		{"api.localhost", true},
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"169.254.169.254", true},
+		{"[::1]", true},
+		{"[2606:2800:220:1::248]", false},
+	}
+	for _, tt := range tests {
+		if got := BlockedHost(tt.host); got != tt.want {
+			t.Errorf("BlockedHost(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestNewDialerRefusesBlockedAddresses(t *testing.T) {
+	dialer := NewDialer(time.Second, func(addr netip.AddrPort) bool {
+		return PublicAddr(addr.Addr())
+	})
+	_, err := dialer.DialContext(context.Background(), "tcp", "127.0.0.1:9")
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("expected ErrBlockedAddress, got %v", err)
+	}
+}
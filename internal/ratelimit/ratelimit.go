@@ -0,0 +1,60 @@
+// Package ratelimit provides an in-memory, fixed-window rate limiter.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+type window struct {
+	start time.Time
+	count int
+}
+
+// Limiter counts events per key over fixed windows. The limit is passed to
+// each call so that keys can be held to different limits.
+type Limiter struct {
+	mu        sync.Mutex
+	window    time.Duration
+	windows   map[string]*window
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New returns a Limiter with windows of the given length.
+func New(length time.Duration) *Limiter {
+	return &Limiter{
+		window:  length,
+		windows: make(map[string]*window),
+		now:     time.Now,
+	}
+}
+
+// Allow records an event for key and reports whether it is within limit for
+// the current window. When it isn't, Allow also returns how long until the
+// window resets.
+func (l *Limiter) Allow(key string, limit int) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) > l.window {
+		for k, w := range l.windows {
+			if now.Sub(w.start) >= l.window {
+				delete(l.windows, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= limit {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
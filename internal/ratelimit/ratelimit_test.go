@@ -0,0 +1,38 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Now()
+	l := New(time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a", 3); !ok {
+			t.Fatalf("expected event %d to be allowed", i+1)
+		}
+	}
+	ok, retryAfter := l.Allow("a", 3)
+	if ok {
+		t.Fatal("expected the fourth event to be limited")
+	}
+	if retryAfter != time.Minute {
+		t.Errorf("expected to retry after a minute, got %v", retryAfter)
+	}
+
+	// Keys are counted separately and can have their own limits.
+	if ok, _ := l.Allow("b", 3); !ok {
+		t.Error("expected another key to be allowed")
+	}
+	if ok, _ := l.Allow("a", 10); !ok {
+		t.Error("expected a higher limit to allow more events")
+	}
+
+	now = now.Add(time.Minute)
+	if ok, _ := l.Allow("a", 3); !ok {
+		t.Error("expected the limit to reset with a new window")
+	}
+}
@@ -0,0 +1,126 @@
+// Package webhooks delivers signed event notifications to endpoints
+// registered by users.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/tsyrdev/chirpy/internal/auth"
+	"github.com/tsyrdev/chirpy/internal/netguard"
+)
+
+// Headers sent with every delivery. The signature uses the same scheme as
+// the Polka webhooks Chirpy receives: v1=<hex HMAC-SHA256 of
+// "<timestamp>.<body>">.
+const (
+	EventHeader     = "X-Chirpy-Event"
+	DeliveryHeader  = "X-Chirpy-Delivery"
+	TimestampHeader = "X-Chirpy-Timestamp"
+	SignatureHeader = "X-Chirpy-Signature"
+)
+
+// MaxAttempts is how many times a delivery is tried before it is given up
+// on.
+const MaxAttempts = 8
+
+// NewSecret returns a random signing secret for a new endpoint.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Backoff returns how long to wait before retrying a delivery that has
+// failed attempts times: 30s, 1m, 2m, 4m and so on.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return 30 * time.Second << (attempts - 1)
+}
+
+// Sender posts deliveries to endpoints.
+type Sender struct {
+	Client *http.Client
+	now    func() time.Time
+}
+
+// NewSender returns a Sender that gives endpoints timeout to respond.
+// Deliveries only go to public addresses and redirects aren't followed, so
+// endpoints can't be used to reach internal services.
+func NewSender(timeout time.Duration) *Sender {
+	return newSender(timeout, func(addr netip.AddrPort) bool {
+		return netguard.PublicAddr(addr.Addr())
+	})
+}
+
+func newSender(timeout time.Duration, allow func(netip.AddrPort) bool) *Sender {
+	transport := &http.Transport{
+		// A proxy would make the connection checks useless.
+		Proxy:                  nil,
+		DialContext:            netguard.NewDialer(timeout, allow).DialContext,
+		TLSHandshakeTimeout:    timeout,
+		ResponseHeaderTimeout:  timeout,
+		MaxResponseHeaderBytes: 16 << 10,
+	}
+	return &Sender{
+		Client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			// A redirect counts as a failed delivery.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		now: time.Now,
+	}
+}
+
+// Delivery is a single event sent to a single endpoint.
+type Delivery struct {
+	ID      string
+	URL     string
+	Secret  string
+	Event   string
+	Payload []byte
+}
+
+// Send posts a delivery and returns the endpoint's status code. Any non-2xx
+// response is returned as an error along with its status code.
+func (s *Sender) Send(ctx context.Context, d Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	ts := s.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(SignatureHeader, "v1="+auth.SignWebhook(d.Secret, ts, d.Payload))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
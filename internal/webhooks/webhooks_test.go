@@ -0,0 +1,112 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tsyrdev/chirpy/internal/auth"
+	"github.com/tsyrdev/chirpy/internal/netguard"
+)
+
+// allowAll lets tests reach httptest servers on the loopback address.
+func allowAll(netip.AddrPort) bool { return true }
+
+func TestSend(t *testing.T) {
+	payload := []byte(`{"type":"chirp.created"}`)
+
+	var got *http.Request
+	var gotBody []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	sender := newSender(time.Second, allowAll)
+	status, err := sender.Send(context.Background(), Delivery{
+		ID:      "delivery-1",
+		URL:     receiver.URL,
+		Secret:  "whsec_test",
+		Event:   "chirp.created",
+		Payload: payload,
+	})
+	if err != nil {
+		t.Fatalf("expected delivery to succeed, got %v", err)
+	}
+	if status != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", status)
+	}
+
+	if got.Header.Get(EventHeader) != "chirp.created" || got.Header.Get(DeliveryHeader) != "delivery-1" {
+		t.Errorf("unexpected event headers: %v", got.Header)
+	}
+	if string(gotBody) != string(payload) {
+		t.Errorf("expected body %s, got %s", payload, gotBody)
+	}
+
+	ts, err := strconv.ParseInt(got.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("invalid timestamp header: %v", err)
+	}
+	want := "v1=" + auth.SignWebhook("whsec_test", ts, payload)
+	if got.Header.Get(SignatureHeader) != want {
+		t.Errorf("expected signature %s, got %s", want, got.Header.Get(SignatureHeader))
+	}
+}
+
+func TestSendFailure(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	status, err := newSender(time.Second, allowAll).Send(context.Background(), Delivery{URL: receiver.URL})
+	if err == nil {
+		t.Fatal("expected an error for a 503 response")
+	}
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", status)
+	}
+}
+
+func TestSendRefusesInternalAddresses(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("internal server should not be reached")
+	}))
+	defer receiver.Close()
+
+	_, err := NewSender(time.Second).Send(context.Background(), Delivery{URL: receiver.URL})
+	if !errors.Is(err, netguard.ErrBlockedAddress) {
+		t.Errorf("expected ErrBlockedAddress, got %v", err)
+	}
+}
+
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect should not be followed")
+	}))
+	defer target.Close()
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer receiver.Close()
+
+	status, err := newSender(time.Second, allowAll).Send(context.Background(), Delivery{URL: receiver.URL})
+	if err == nil || status != http.StatusTemporaryRedirect {
+		t.Errorf("expected a failed 307 delivery, got %d %v", status, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if Backoff(1) != 30*time.Second || Backoff(2) != time.Minute || Backoff(4) != 4*time.Minute {
+		t.Errorf("unexpected backoff: %v %v %v", Backoff(1), Backoff(2), Backoff(4))
+	}
+}
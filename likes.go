@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp could not be found")
+		return
+	}
+	blocked, err := cfg.isBlocked(r.Context(), userID, dbChirp.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not like chirp")
+		return
+	}
+	if blocked || !chirpVisible(dbChirp, userID) {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp could not be found")
+		return
+	}
+
+	liked, err := cfg.dbQueries.LikeChirp(r.Context(), database.LikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not like chirp")
+		return
+	}
+	// Liking a chirp again changes nothing and notifies no one.
+	if liked > 0 {
+		cfg.notify(r.Context(), dbChirp.UserID, userID, notificationLike, uuid.NullUUID{UUID: chirpID, Valid: true})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	_, err = cfg.dbQueries.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not unlike chirp")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
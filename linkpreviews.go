@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/internal/linkpreview"
+)
+
+const (
+	// maxChirpLinks is how many links in a chirp get a preview.
+	maxChirpLinks = 4
+	// linkPreviewBatch is how many previews are fetched per run.
+	linkPreviewBatch = 20
+	// maxLinkPreviewAttempts is how many times a page is tried before its
+	// preview is given up on.
+	maxLinkPreviewAttempts = 3
+)
+
+// Statuses of a cached link preview.
+const (
+	previewPending = "pending"
+	previewReady   = "ready"
+	previewFailed  = "failed"
+)
+
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ImageURL    string `json:"image_url"`
+}
+
+// attachLinks records the links in a chirp's body and queues previews for
+// the ones that don't have one yet. The previews are fetched in the
+// background by runLinkPreviews.
+func attachLinks(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, body string) error {
+	urls := linkpreview.ExtractURLs(body, maxChirpLinks)
+	if len(urls) == 0 {
+		return nil
+	}
+	if err := qtx.EnqueueLinkPreviews(ctx, urls); err != nil {
+		return err
+	}
+	return qtx.AddChirpLinks(ctx, database.AddChirpLinksParams{
+		ChirpID: chirpID,
+		Urls:    urls,
+	})
+}
+
+// linkPreviewsByChirpID loads the ready previews of the given chirps.
+func (cfg *apiConfig) linkPreviewsByChirpID(ctx context.Context, chirpIDs []uuid.UUID) (map[uuid.UUID][]LinkPreview, error) {
+	dbPreviews, err := cfg.dbQueries.GetChirpLinkPreviews(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	previews := make(map[uuid.UUID][]LinkPreview)
+	for _, dbPreview := range dbPreviews {
+		previews[dbPreview.ChirpID] = append(previews[dbPreview.ChirpID], LinkPreview{
+			URL:         dbPreview.Url,
+			Title:       dbPreview.Title,
+			Description: dbPreview.Description,
+			ImageURL:    dbPreview.ImageUrl,
+		})
+	}
+	return previews, nil
+}
+
+// fetchLinkPreviews fetches a batch of pending previews.
+func (cfg *apiConfig) fetchLinkPreviews(ctx context.Context) error {
+	dbPreviews, err := cfg.dbQueries.ClaimLinkPreviews(ctx, linkPreviewBatch)
+	if err != nil {
+		return err
+	}
+
+	for _, dbPreview := range dbPreviews {
+		preview, fetchErr := cfg.linkPreviews.Fetch(ctx, dbPreview.Url)
+
+		finish := database.FinishLinkPreviewParams{
+			Url:           dbPreview.Url,
+			Status:        previewReady,
+			NextAttemptAt: time.Now(),
+			Title:         preview.Title,
+			Description:   preview.Description,
+			ImageUrl:      preview.ImageURL,
+		}
+		if fetchErr != nil {
+			// Pages that are off limits or have nothing to show won't
+			// change on a retry.
+			attempts := int(dbPreview.Attempts) + 1
+			finish.Status = previewPending
+			finish.NextAttemptAt = time.Now().Add(time.Duration(attempts) * time.Minute)
+			if attempts >= maxLinkPreviewAttempts ||
+				errors.Is(fetchErr, linkpreview.ErrBlockedAddress) ||
+				errors.Is(fetchErr, linkpreview.ErrNoPreview) {
+				finish.Status = previewFailed
+			}
+		}
+		if err := cfg.dbQueries.FinishLinkPreview(ctx, finish); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runLinkPreviews periodically fetches pending link previews. It is safe to
+// run on several instances at once.
+func (cfg *apiConfig) runLinkPreviews(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := cfg.fetchLinkPreviews(context.Background()); err != nil {
+			log.Printf("Error fetching link previews: %v", err)
+		}
+	}
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+const (
+	maxListNameLength        = 50
+	maxListDescriptionLength = 200
+	maxListMembers           = 500
+	defaultListChirpsLimit   = 20
+	maxListChirpsLimit       = 100
+)
+
+// Visibilities of a list. Private lists are only visible to their owner.
+const (
+	listPublic  = "public"
+	listPrivate = "private"
+)
+
+type List struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Owner       *Author   `json:"owner"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Visibility  string    `json:"visibility"`
+	// Members is only filled in when a single list is requested.
+	Members []*Author `json:"members,omitempty"`
+}
+
+// listsFromDB converts lists into API responses, embedding their owners.
+func (cfg *apiConfig) listsFromDB(ctx context.Context, dbLists []database.List) ([]List, error) {
+	ownerIDs := make([]uuid.UUID, 0, len(dbLists))
+	for _, dbList := range dbLists {
+		if !slices.Contains(ownerIDs, dbList.OwnerID) {
+			ownerIDs = append(ownerIDs, dbList.OwnerID)
+		}
+	}
+	owners, err := cfg.authorsByID(ctx, ownerIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	lists := make([]List, 0, len(dbLists))
+	for _, dbList := range dbLists {
+		lists = append(lists, List{
+			ID:          dbList.ID,
+			CreatedAt:   dbList.CreatedAt,
+			UpdatedAt:   dbList.UpdatedAt,
+			Owner:       owners[dbList.OwnerID],
+			Name:        dbList.Name,
+			Description: dbList.Description,
+			Visibility:  dbList.Visibility,
+		})
+	}
+	return lists, nil
+}
+
+type listParams struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Visibility  string `json:"visibility"`
+}
+
+// decodeListParams reads and validates the settings of a list from a
+// request. Lists are public unless asked otherwise.
+func decodeListParams(w http.ResponseWriter, r *http.Request) (listParams, bool) {
+	var params listParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return listParams{}, false
+	}
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" || len(params.Name) > maxListNameLength {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("List names must be 1-%d characters", maxListNameLength))
+		return listParams{}, false
+	}
+	if len(params.Description) > maxListDescriptionLength {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("List descriptions can be at most %d characters", maxListDescriptionLength))
+		return listParams{}, false
+	}
+	if params.Visibility == "" {
+		params.Visibility = listPublic
+	}
+	if params.Visibility != listPublic && params.Visibility != listPrivate {
+		utils.RespondWithError(w, http.StatusBadRequest, "Visibility must be public or private")
+		return listParams{}, false
+	}
+	return params, true
+}
+
+// listForViewer returns the list in the request path if the viewer may see
+// it. Private lists, and lists whose owner blocked the viewer or was blocked
+// by them, get a 404 so they can't be probed for.
+func (cfg *apiConfig) listForViewer(w http.ResponseWriter, r *http.Request, viewerID uuid.UUID) (database.List, bool) {
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid list ID")
+		return database.List{}, false
+	}
+
+	dbList, err := cfg.dbQueries.GetList(r.Context(), listID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "List could not be found")
+		return database.List{}, false
+	}
+	if dbList.Visibility == listPrivate && dbList.OwnerID != viewerID {
+		utils.RespondWithError(w, http.StatusNotFound, "List could not be found")
+		return database.List{}, false
+	}
+	blocked, err := cfg.isBlocked(r.Context(), viewerID, dbList.OwnerID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the list")
+		return database.List{}, false
+	}
+	if blocked {
+		utils.RespondWithError(w, http.StatusNotFound, "List could not be found")
+		return database.List{}, false
+	}
+	return dbList, true
+}
+
+// ownedList returns the list in the request path if it belongs to userID.
+func (cfg *apiConfig) ownedList(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.List, bool) {
+	dbList, ok := cfg.listForViewer(w, r, userID)
+	if !ok {
+		return database.List{}, false
+	}
+	if dbList.OwnerID != userID {
+		utils.RespondWithError(w, http.StatusForbidden, "List does not belong to user")
+		return database.List{}, false
+	}
+	return dbList, true
+}
+
+func (cfg *apiConfig) handlerCreateList(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	params, ok := decodeListParams(w, r)
+	if !ok {
+		return
+	}
+
+	dbList, err := cfg.dbQueries.CreateList(r.Context(), database.CreateListParams{
+		OwnerID:     userID,
+		Name:        params.Name,
+		Description: params.Description,
+		Visibility:  params.Visibility,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create the list")
+		return
+	}
+	lists, err := cfg.listsFromDB(r.Context(), []database.List{dbList})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create the list")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, lists[0])
+}
+
+func (cfg *apiConfig) handlerGetLists(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsRead)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbLists, err := cfg.dbQueries.GetListsByOwner(r.Context(), database.GetListsByOwnerParams{
+		OwnerID:        userID,
+		IncludePrivate: true,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get lists")
+		return
+	}
+	lists, err := cfg.listsFromDB(r.Context(), dbLists)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get lists")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, lists)
+}
+
+func (cfg *apiConfig) handlerGetUserLists(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	viewerID, err := cfg.viewer(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	handle := strings.ToLower(r.PathValue("handle"))
+	dbUser, err := cfg.dbQueries.GetUserByHandle(r.Context(), sql.NullString{String: handle, Valid: true})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User could not be found")
+		return
+	}
+	blocked, err := cfg.isBlocked(r.Context(), viewerID, dbUser.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get lists")
+		return
+	}
+	if blocked {
+		utils.RespondWithJSON(w, http.StatusOK, []List{})
+		return
+	}
+
+	dbLists, err := cfg.dbQueries.GetListsByOwner(r.Context(), database.GetListsByOwnerParams{
+		OwnerID:        dbUser.ID,
+		IncludePrivate: viewerID == dbUser.ID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get lists")
+		return
+	}
+	lists, err := cfg.listsFromDB(r.Context(), dbLists)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get lists")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, lists)
+}
+
+func (cfg *apiConfig) handlerGetList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	viewerID, err := cfg.viewer(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbList, ok := cfg.listForViewer(w, r, viewerID)
+	if !ok {
+		return
+	}
+
+	memberIDs, err := cfg.dbQueries.GetListMembers(r.Context(), dbList.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the list")
+		return
+	}
+	members, err := cfg.authorsByID(r.Context(), memberIDs)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the list")
+		return
+	}
+	lists, err := cfg.listsFromDB(r.Context(), []database.List{dbList})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the list")
+		return
+	}
+
+	list := lists[0]
+	list.Members = make([]*Author, 0, len(memberIDs))
+	for _, id := range memberIDs {
+		if member, ok := members[id]; ok {
+			list.Members = append(list.Members, member)
+		}
+	}
+	utils.RespondWithJSON(w, http.StatusOK, list)
+}
+
+func (cfg *apiConfig) handlerUpdateList(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid list ID")
+		return
+	}
+
+	params, ok := decodeListParams(w, r)
+	if !ok {
+		return
+	}
+
+	dbList, err := cfg.dbQueries.UpdateList(r.Context(), database.UpdateListParams{
+		ID:          listID,
+		OwnerID:     userID,
+		Name:        params.Name,
+		Description: params.Description,
+		Visibility:  params.Visibility,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "List could not be found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not update the list")
+		return
+	}
+	lists, err := cfg.listsFromDB(r.Context(), []database.List{dbList})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not update the list")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, lists[0])
+}
+
+func (cfg *apiConfig) handlerDeleteList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid list ID")
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteList(r.Context(), database.DeleteListParams{
+		ID:      listID,
+		OwnerID: userID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not delete the list")
+		return
+	}
+	if deleted == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "List could not be found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerAddListMember(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbList, ok := cfg.ownedList(w, r, userID)
+	if !ok {
+		return
+	}
+
+	handle := strings.ToLower(r.PathValue("handle"))
+	member, err := cfg.dbQueries.GetUserByHandle(r.Context(), sql.NullString{String: handle, Valid: true})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User could not be found")
+		return
+	}
+	blocked, err := cfg.isBlocked(r.Context(), userID, member.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not add the user to the list")
+		return
+	}
+	if blocked {
+		utils.RespondWithError(w, http.StatusForbidden, "Can't add a blocked user to a list")
+		return
+	}
+
+	count, err := cfg.dbQueries.CountListMembers(r.Context(), dbList.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not add the user to the list")
+		return
+	}
+	if count >= maxListMembers {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Lists can have at most %d members", maxListMembers))
+		return
+	}
+
+	err = cfg.dbQueries.AddListMember(r.Context(), database.AddListMemberParams{
+		ListID: dbList.ID,
+		UserID: member.ID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not add the user to the list")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRemoveListMember(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbList, ok := cfg.ownedList(w, r, userID)
+	if !ok {
+		return
+	}
+
+	handle := strings.ToLower(r.PathValue("handle"))
+	member, err := cfg.dbQueries.GetUserByHandle(r.Context(), sql.NullString{String: handle, Valid: true})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User could not be found")
+		return
+	}
+
+	err = cfg.dbQueries.RemoveListMember(r.Context(), database.RemoveListMemberParams{
+		ListID: dbList.ID,
+		UserID: member.ID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not remove the user from the list")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetListChirps(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	viewerID, err := cfg.viewer(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbList, ok := cfg.listForViewer(w, r, viewerID)
+	if !ok {
+		return
+	}
+
+	before, limit, err := parsePageParams(r, defaultListChirpsLimit, maxListChirpsLimit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbChirps, err := cfg.dbQueries.GetListChirps(r.Context(), database.GetListChirpsParams{
+		ListID:     dbList.ID,
+		Before:     before,
+		MaxResults: limit,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the list's chirps")
+		return
+	}
+	hidden, err := cfg.hiddenUsers(r.Context(), viewerID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the list's chirps")
+		return
+	}
+
+	// The cursor comes from the last chirp fetched so that paging carries
+	// on past chirps the viewer doesn't get to see.
+	var nextBefore *time.Time
+	if len(dbChirps) == int(limit) {
+		last := dbChirps[len(dbChirps)-1].CreatedAt
+		nextBefore = &last
+	}
+	dbChirps = slices.DeleteFunc(dbChirps, func(dbChirp database.Chirp) bool {
+		return slices.Contains(hidden, dbChirp.UserID) || !chirpVisible(dbChirp, viewerID)
+	})
+	chirps, err := cfg.chirpsFromDB(r.Context(), dbChirps)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the list's chirps")
+		return
+	}
+	if err := cfg.addViewerVotes(r.Context(), chirps, viewerID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the list's chirps")
+		return
+	}
+	cfg.recordViews(viewerID, dbChirps)
+
+	// next_before is the cursor for the following page, if there may be one.
+	response := struct {
+		Chirps     []Chirp    `json:"chirps"`
+		NextBefore *time.Time `json:"next_before"`
+	}{
+		Chirps:     chirps,
+		NextBefore: nextBefore,
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
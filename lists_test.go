@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+)
+
+// createTestList creates a list owned by the user behind token.
+func createTestList(t *testing.T, cfg *apiConfig, token, visibility string) List {
+	t.Helper()
+	rec := serveTestRecorder(cfg.handlerCreateList, http.MethodPost, "/api/lists", "/api/lists", token, `{"name":"test","visibility":"`+visibility+`"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	var list List
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+// addTestListMember adds a user to a list and reports the response status.
+func addTestListMember(cfg *apiConfig, list List, token string, member database.User) int {
+	path := "/api/lists/" + list.ID.String() + "/members/" + member.Handle.String
+	return serveTestRequest(cfg.handlerAddListMember, http.MethodPost, "/api/lists/{listID}/members/{handle}", path, token, "")
+}
+
+// getTestListChirps returns the IDs of the chirps the viewer sees in a list.
+func getTestListChirps(t *testing.T, cfg *apiConfig, list List, token string) []uuid.UUID {
+	t.Helper()
+	path := "/api/lists/" + list.ID.String() + "/chirps"
+	rec := serveTestRecorder(cfg.handlerGetListChirps, http.MethodGet, "/api/lists/{listID}/chirps", path, token, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var response struct {
+		Chirps []Chirp `json:"chirps"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]uuid.UUID, 0, len(response.Chirps))
+	for _, chirp := range response.Chirps {
+		ids = append(ids, chirp.ID)
+	}
+	return ids
+}
+
+func TestListChirpsRespectBlocksAndMutes(t *testing.T) {
+	cfg := newTestConfig(t)
+	owner, token := createTestUser(t, cfg)
+	_, otherToken := createTestUser(t, cfg)
+	list := createTestList(t, cfg, token, listPublic)
+
+	var members []database.User
+	var chirpIDs []uuid.UUID
+	for range 3 {
+		member, _ := createTestUser(t, cfg)
+		if status := addTestListMember(cfg, list, token, member); status != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d", status)
+		}
+		members = append(members, member)
+		chirpIDs = append(chirpIDs, createTestChirp(t, cfg, member.ID, "listed").ID)
+	}
+
+	err := cfg.dbQueries.MuteUser(context.Background(), database.MuteUserParams{
+		MuterID: owner.ID,
+		MutedID: members[1].ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.dbQueries.BlockUser(context.Background(), database.BlockUserParams{
+		BlockerID: members[2].ID,
+		BlockedID: owner.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := getTestListChirps(t, cfg, list, token); len(got) != 1 || got[0] != chirpIDs[0] {
+		t.Errorf("expected only the chirp by the unmuted, unblocked member, got %v", got)
+	}
+	// Other viewers still see every member's chirps.
+	if got := getTestListChirps(t, cfg, list, otherToken); len(got) != 3 {
+		t.Errorf("expected 3 chirps, got %d", len(got))
+	}
+}
+
+func TestPrivateListIsNotFound(t *testing.T) {
+	cfg := newTestConfig(t)
+	_, token := createTestUser(t, cfg)
+	_, otherToken := createTestUser(t, cfg)
+	list := createTestList(t, cfg, token, listPrivate)
+
+	path := "/api/lists/" + list.ID.String()
+	if status := serveTestRequest(cfg.handlerGetList, http.MethodGet, "/api/lists/{listID}", path, otherToken, ""); status != http.StatusNotFound {
+		t.Errorf("expected 404 for another user, got %d", status)
+	}
+	if status := serveTestRequest(cfg.handlerGetList, http.MethodGet, "/api/lists/{listID}", path, "", ""); status != http.StatusNotFound {
+		t.Errorf("expected 404 for anonymous viewers, got %d", status)
+	}
+	if status := serveTestRequest(cfg.handlerGetList, http.MethodGet, "/api/lists/{listID}", path, token, ""); status != http.StatusOK {
+		t.Errorf("expected 200 for the owner, got %d", status)
+	}
+}
+
+func TestCannotAddBlockedUserToList(t *testing.T) {
+	cfg := newTestConfig(t)
+	owner, token := createTestUser(t, cfg)
+	member, _ := createTestUser(t, cfg)
+	list := createTestList(t, cfg, token, listPublic)
+
+	err := cfg.dbQueries.BlockUser(context.Background(), database.BlockUserParams{
+		BlockerID: member.ID,
+		BlockedID: owner.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := addTestListMember(cfg, list, token, member); status != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", status)
+	}
+}
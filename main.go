@@ -4,9 +4,16 @@ import (
 	"log"
 	"net/http"
 	"sync/atomic"
+	"time"
 	"os"
 	"database/sql"
+	"github.com/tsyrdev/chirpy/internal/blobstore"
 	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/internal/events"
+	"github.com/tsyrdev/chirpy/internal/impressions"
+	"github.com/tsyrdev/chirpy/internal/linkpreview"
+	"github.com/tsyrdev/chirpy/internal/ratelimit"
+	"github.com/tsyrdev/chirpy/internal/webhooks"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -14,10 +21,20 @@ import (
 
 type apiConfig struct {
 	fileserverHits 	atomic.Int32
+	db				*sql.DB
 	dbQueries		*database.Queries	
+	blobStore		blobstore.BlobStore
 	platform		string
+	baseURL			string
 	secret			string
-	polkaKey		string
+	polkaKeys		[]string
+	requirePolkaSignature	bool
+	writeLimiter	*ratelimit.Limiter
+	webhookSender	*webhooks.Sender
+	linkPreviews	*linkpreview.Fetcher
+	impressions		*impressions.Counter
+	events			*events.Hub
+	eventRelay		*events.Relay
 }
 
 func main() {
@@ -29,14 +46,36 @@ func main() {
 	}
 	defer db.Close()
 
+	baseURL, err := parseBaseURL(os.Getenv("BASE_URL"))
+	if err != nil {
+		log.Fatal("BASE_URL must be set to the server's public address: ", err)
+	}
+
+	blobStore, err := blobStoreFromEnv()
+	if err != nil {
+		log.Fatal("unable to set up media storage: ", err)
+	}
+
+	hub := events.NewHub(1000, 64)
+
 	const filepathRoot = "."
 	const port = "8080"
 	var apiCfg = apiConfig{
 		fileserverHits: atomic.Int32{},
+		db: db,
 		dbQueries: database.New(db),
+		blobStore: blobStore,
 		platform: os.Getenv("PLATFORM"),
+		baseURL: baseURL,
 		secret: os.Getenv("SECRET"),
-		polkaKey: os.Getenv("POLKA_KEY"),
+		polkaKeys: parsePolkaKeys(os.Getenv("POLKA_KEY")),
+		requirePolkaSignature: os.Getenv("POLKA_REQUIRE_SIGNATURE") == "true",
+		writeLimiter: ratelimit.New(time.Minute),
+		webhookSender: webhooks.NewSender(10 * time.Second),
+		linkPreviews: linkpreview.NewFetcher(5 * time.Second, 512 << 10),
+		impressions: impressions.NewCounter(),
+		events: hub,
+		eventRelay: events.NewRelay(hub, db, dbURL),
 	}
 
 	mux := http.NewServeMux()
@@ -45,22 +84,113 @@ func main() {
 
 	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerGetMetrics)
 	mux.HandleFunc("POST /admin/reset", apiCfg.handlerResetMetrics)
+	mux.HandleFunc("GET /admin/webhooks", apiCfg.handlerListWebhookEvents)
+	mux.HandleFunc("POST /admin/webhooks/{eventID}/replay", apiCfg.handlerReplayWebhookEvent)
+	mux.HandleFunc("GET /admin/reports", apiCfg.handlerGetReportQueue)
+	mux.HandleFunc("POST /admin/chirps/{chirpID}/moderate", apiCfg.handlerModerateChirp)
+	mux.HandleFunc("GET /admin/moderation/actions", apiCfg.handlerGetModerationActions)
+	mux.HandleFunc("POST /admin/users/{userID}/ban", apiCfg.handlerBanUser)
+	mux.HandleFunc("DELETE /admin/users/{userID}/ban", apiCfg.handlerUnbanUser)
+	mux.HandleFunc("POST /admin/users/{userID}/suspend", apiCfg.handlerSuspendUser)
+	mux.HandleFunc("DELETE /admin/users/{userID}/suspend", apiCfg.handlerUnsuspendUser)
+
+	mux.HandleFunc("GET /l/{code}", apiCfg.handlerFollowShortLink)
 
 	mux.HandleFunc("GET /api/healthz", handlerReadiness)
 	mux.HandleFunc("POST /api/users", apiCfg.handlerCreateUser)
 	mux.HandleFunc("POST /api/chirps", apiCfg.handlerCreateChirp)
 	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetAllChirps)
+	mux.HandleFunc("POST /api/chirps/thread", apiCfg.handlerCreateThread)
+	mux.HandleFunc("GET /api/chirps/scheduled", apiCfg.handlerGetScheduledChirps)
+	mux.HandleFunc("GET /api/chirps/deleted", apiCfg.handlerGetDeletedChirps)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirp)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.handlerEditChirp)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/like", apiCfg.handlerLikeChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/like", apiCfg.handlerUnlikeChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", apiCfg.handlerBookmarkChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", apiCfg.handlerRemoveBookmark)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/report", apiCfg.handlerReportChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/vote", apiCfg.handlerVotePoll)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/links", apiCfg.handlerGetChirpLinks)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", apiCfg.handlerRestoreChirp)
+	mux.HandleFunc("GET /api/bookmarks", apiCfg.handlerGetBookmarks)
+	mux.HandleFunc("POST /api/lists", apiCfg.handlerCreateList)
+	mux.HandleFunc("GET /api/lists", apiCfg.handlerGetLists)
+	mux.HandleFunc("GET /api/lists/{listID}", apiCfg.handlerGetList)
+	mux.HandleFunc("PUT /api/lists/{listID}", apiCfg.handlerUpdateList)
+	mux.HandleFunc("DELETE /api/lists/{listID}", apiCfg.handlerDeleteList)
+	mux.HandleFunc("GET /api/lists/{listID}/chirps", apiCfg.handlerGetListChirps)
+	mux.HandleFunc("POST /api/lists/{listID}/members/{handle}", apiCfg.handlerAddListMember)
+	mux.HandleFunc("DELETE /api/lists/{listID}/members/{handle}", apiCfg.handlerRemoveListMember)
+	mux.HandleFunc("POST /api/drafts", apiCfg.handlerCreateDraft)
+	mux.HandleFunc("GET /api/drafts", apiCfg.handlerGetDrafts)
+	mux.HandleFunc("GET /api/drafts/{draftID}", apiCfg.handlerGetDraft)
+	mux.HandleFunc("PUT /api/drafts/{draftID}", apiCfg.handlerUpdateDraft)
+	mux.HandleFunc("DELETE /api/drafts/{draftID}", apiCfg.handlerDeleteDraft)
+	mux.HandleFunc("POST /api/drafts/{draftID}/publish", apiCfg.handlerPublishDraft)
+	mux.HandleFunc("GET /api/stream", apiCfg.handlerStream)
+	mux.HandleFunc("GET /api/ws", apiCfg.handlerWebSocket)
+	mux.HandleFunc("POST /api/media", apiCfg.handlerUploadMedia)
+	mux.HandleFunc("GET /api/media/{mediaID}", apiCfg.handlerGetMedia)
+	mux.HandleFunc("GET /api/media/{mediaID}/thumbnail", apiCfg.handlerGetMediaThumbnail)
 	mux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
 	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevokeRefresh)
 	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUser)
+	mux.HandleFunc("DELETE /api/users", apiCfg.handlerDeleteUser)
+	mux.HandleFunc("GET /api/users/export", apiCfg.handlerExportUser)
+	mux.HandleFunc("PUT /api/users/profile", apiCfg.handlerUpdateProfile)
+	mux.HandleFunc("POST /api/users/avatar", apiCfg.handlerUploadAvatar)
+	mux.HandleFunc("GET /api/users/subscription", apiCfg.handlerGetSubscription)
+	mux.HandleFunc("GET /api/users/analytics", apiCfg.handlerGetUserAnalytics)
+	mux.HandleFunc("GET /api/users/blocks", apiCfg.handlerGetBlocks)
+	mux.HandleFunc("GET /api/users/mutes", apiCfg.handlerGetMutes)
+	mux.HandleFunc("GET /api/users/{handle}", apiCfg.handlerGetProfile)
+	mux.HandleFunc("POST /api/users/{handle}/follow", apiCfg.handlerFollowUser)
+	mux.HandleFunc("DELETE /api/users/{handle}/follow", apiCfg.handlerUnfollowUser)
+	mux.HandleFunc("POST /api/users/{handle}/block", apiCfg.handlerBlockUser)
+	mux.HandleFunc("DELETE /api/users/{handle}/block", apiCfg.handlerUnblockUser)
+	mux.HandleFunc("POST /api/users/{handle}/mute", apiCfg.handlerMuteUser)
+	mux.HandleFunc("DELETE /api/users/{handle}/mute", apiCfg.handlerUnmuteUser)
+	mux.HandleFunc("GET /api/users/{handle}/lists", apiCfg.handlerGetUserLists)
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerUpgradeUser)
+	mux.HandleFunc("POST /api/keys", apiCfg.handlerCreateAPIKey)
+	mux.HandleFunc("GET /api/keys", apiCfg.handlerGetAPIKeys)
+	mux.HandleFunc("DELETE /api/keys/{keyID}", apiCfg.handlerRevokeAPIKey)
+	mux.HandleFunc("GET /api/notifications", apiCfg.handlerGetNotifications)
+	mux.HandleFunc("POST /api/notifications/read", apiCfg.handlerMarkAllNotificationsRead)
+	mux.HandleFunc("POST /api/notifications/{notificationID}/read", apiCfg.handlerMarkNotificationRead)
+	mux.HandleFunc("GET /api/notifications/preferences", apiCfg.handlerGetNotificationPreferences)
+	mux.HandleFunc("PUT /api/notifications/preferences", apiCfg.handlerUpdateNotificationPreferences)
+	mux.HandleFunc("POST /api/conversations", apiCfg.handlerCreateConversation)
+	mux.HandleFunc("GET /api/conversations", apiCfg.handlerGetConversations)
+	mux.HandleFunc("GET /api/conversations/{conversationID}", apiCfg.handlerGetConversation)
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", apiCfg.handlerGetMessages)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", apiCfg.handlerSendMessage)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/read", apiCfg.handlerMarkConversationRead)
+	mux.HandleFunc("POST /api/webhooks", apiCfg.handlerCreateWebhook)
+	mux.HandleFunc("GET /api/webhooks", apiCfg.handlerGetWebhooks)
+	mux.HandleFunc("DELETE /api/webhooks/{webhookID}", apiCfg.handlerDeleteWebhook)
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries", apiCfg.handlerGetWebhookDeliveries)
+
+	go apiCfg.runSubscriptionExpiry(time.Minute)
+	go apiCfg.runWebhookDeliveries(5 * time.Second)
+	go apiCfg.runScheduledChirps(10 * time.Second)
+	go apiCfg.runChirpPurge(time.Hour)
+	go apiCfg.runMediaSweep(time.Hour)
+	go apiCfg.runLinkPreviews(5 * time.Second)
+	go apiCfg.runImpressionFlush(30 * time.Second)
+	go apiCfg.runChirpScoring(5 * time.Minute)
+	go func() {
+		if err := apiCfg.eventRelay.Listen(); err != nil {
+			log.Printf("Event relay stopped: %v", err)
+		}
+	}()
 
 	srv := &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: apiCfg.middlewareAccountStatus(mux),
 	}
 
 	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
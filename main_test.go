@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/auth"
+	"github.com/tsyrdev/chirpy/internal/blobstore"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/internal/events"
+	"github.com/tsyrdev/chirpy/internal/impressions"
+	"github.com/tsyrdev/chirpy/internal/linkpreview"
+	"github.com/tsyrdev/chirpy/internal/ratelimit"
+	"github.com/tsyrdev/chirpy/internal/webhooks"
+)
+
+const testSecret = "test-secret"
+
+// newTestConfig connects to the database in TEST_DB_URL, which must have
+// every migration in sql/schema applied. Tests that need a database are
+// skipped when it isn't set.
+func newTestConfig(t *testing.T) *apiConfig {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	blobStore, err := blobstore.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hub := events.NewHub(100, 16)
+	return &apiConfig{
+		db:            db,
+		dbQueries:     database.New(db),
+		blobStore:     blobStore,
+		secret:        testSecret,
+		baseURL:       "http://chirpy.test",
+		writeLimiter:  ratelimit.New(time.Minute),
+		webhookSender: webhooks.NewSender(time.Second),
+		linkPreviews:  linkpreview.NewFetcher(time.Second, 512<<10),
+		impressions:   impressions.NewCounter(),
+		events:        hub,
+		eventRelay:    events.NewRelay(hub, db, dbURL),
+	}
+}
+
+// createTestUser creates a user with a random handle, removed again when the
+// test ends, and returns it with an access token.
+func createTestUser(t *testing.T, cfg *apiConfig) (database.User, string) {
+	t.Helper()
+	ctx := context.Background()
+	name := "t_" + uuid.NewString()[:8]
+
+	dbUser, err := cfg.dbQueries.CreateUser(ctx, database.CreateUserParams{
+		Email:          name + "@example.com",
+		HashedPassword: "unused",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cfg.dbQueries.DeleteUser(context.Background(), dbUser.ID) })
+
+	dbUser, err = cfg.dbQueries.UpdateUserProfile(ctx, database.UpdateUserProfileParams{
+		ID:     dbUser.ID,
+		Handle: sql.NullString{String: name, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := auth.MakeJWT(dbUser.ID, testSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dbUser, token
+}
+
+// createTestChirp publishes a chirp by userID.
+func createTestChirp(t *testing.T, cfg *apiConfig, userID uuid.UUID, body string) database.Chirp {
+	t.Helper()
+	dbChirp, err := cfg.dbQueries.CreateChirps(context.Background(), database.CreateChirpsParams{
+		Body:   body,
+		UserID: userID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dbChirp
+}
+
+// makeTestAdmin gives a test user admin rights.
+func makeTestAdmin(t *testing.T, cfg *apiConfig, userID uuid.UUID) {
+	t.Helper()
+	if _, err := cfg.db.ExecContext(context.Background(), "UPDATE users SET is_admin = true WHERE id = $1", userID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serveTestRequest calls handler with a request authenticated with token,
+// or an anonymous one if it is empty, and returns the response status.
+func serveTestRequest(handler http.HandlerFunc, method, pattern, path, token, body string) int {
+	return serveTestRecorder(handler, method, pattern, path, token, body).Code
+}
+
+// serveTestRecorder is serveTestRequest for tests that check the response
+// body as well.
+func serveTestRecorder(handler http.HandlerFunc, method, pattern, path, token, body string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc(method+" "+pattern, handler)
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+// countNotifications returns how many notifications of the given type the
+// user has.
+func countNotifications(t *testing.T, cfg *apiConfig, userID uuid.UUID, notificationType string) int {
+	t.Helper()
+	dbNotifications, err := cfg.dbQueries.GetNotifications(context.Background(), database.GetNotificationsParams{
+		UserID:     userID,
+		MaxResults: 100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for _, dbNotification := range dbNotifications {
+		if dbNotification.Type == notificationType {
+			count++
+		}
+	}
+	return count
+}
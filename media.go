@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/auth"
+	"github.com/tsyrdev/chirpy/internal/blobstore"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/internal/media"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+const maxChirpMedia = 4
+
+// unattachedMediaRetention is how long an upload can stay out of every chirp
+// before it is deleted. Avatars in use are kept.
+const unattachedMediaRetention = 24 * time.Hour
+
+type Media struct {
+	ID           uuid.UUID `json:"id"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+}
+
+func mediaFromDB(dbMedia database.MediaFile) Media {
+	return Media{
+		ID:           dbMedia.ID,
+		ContentType:  dbMedia.ContentType,
+		Width:        dbMedia.Width,
+		Height:       dbMedia.Height,
+		URL:          "/api/media/" + dbMedia.ID.String(),
+		ThumbnailURL: "/api/media/" + dbMedia.ID.String() + "/thumbnail",
+	}
+}
+
+// blobStoreFromEnv picks the storage backend for uploads. Files are kept on
+// the local filesystem unless MEDIA_STORE=s3.
+func blobStoreFromEnv() (blobstore.BlobStore, error) {
+	if os.Getenv("MEDIA_STORE") == "s3" {
+		return blobstore.NewS3(blobstore.S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY"),
+			SecretAccessKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	}
+
+	dir := os.Getenv("MEDIA_DIR")
+	if dir == "" {
+		dir = "./media"
+	}
+	return blobstore.NewLocal(dir)
+}
+
+// readUpload returns the contents of the "file" field of a multipart upload.
+func readUpload(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxUploadBytes+1<<20)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, media.MaxUploadBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > media.MaxUploadBytes {
+		return nil, media.ErrTooLarge
+	}
+	return data, nil
+}
+
+func uploadErrorStatus(err error) (int, string) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, media.ErrTooLarge), errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge, "Image is too large"
+	case errors.Is(err, media.ErrDimensionsTooLarge):
+		return http.StatusBadRequest, fmt.Sprintf("Images can be at most %d pixels on a side and %d pixels in total", media.MaxDimension, media.MaxPixels)
+	case errors.Is(err, media.ErrUnsupportedType):
+		return http.StatusUnsupportedMediaType, "Only JPEG and PNG images are supported"
+	case errors.Is(err, http.ErrMissingFile), errors.Is(err, http.ErrNotMultipart):
+		return http.StatusBadRequest, "Upload an image in the \"file\" form field"
+	default:
+		return http.StatusInternalServerError, "Could not store the image"
+	}
+}
+
+func (cfg *apiConfig) storeImage(ctx context.Context, userID uuid.UUID, data []byte) (database.MediaFile, error) {
+	img, err := media.Process(data)
+	if err != nil {
+		return database.MediaFile{}, err
+	}
+
+	id := uuid.New()
+	ext := media.Extension(img.ContentType)
+	key := "media/" + id.String() + ext
+	thumbnailKey := "media/" + id.String() + "_thumb" + ext
+
+	if err := cfg.blobStore.Put(ctx, key, img.ContentType, img.Data); err != nil {
+		return database.MediaFile{}, err
+	}
+	if err := cfg.blobStore.Put(ctx, thumbnailKey, img.ContentType, img.Thumbnail); err != nil {
+		return database.MediaFile{}, err
+	}
+
+	return cfg.dbQueries.CreateMediaFile(ctx, database.CreateMediaFileParams{
+		ID:           id,
+		UserID:       userID,
+		ContentType:  img.ContentType,
+		SizeBytes:    int32(len(img.Data)),
+		Width:        int32(img.Width),
+		Height:       int32(img.Height),
+		StorageKey:   key,
+		ThumbnailKey: thumbnailKey,
+	})
+}
+
+// deleteMediaBlobs removes the stored images and thumbnails of media whose
+// rows were deleted. Failures are logged, a leftover blob only costs
+// storage.
+func (cfg *apiConfig) deleteMediaBlobs(ctx context.Context, dbMedia []database.MediaFile) {
+	for _, m := range dbMedia {
+		for _, key := range []string{m.StorageKey, m.ThumbnailKey} {
+			if err := cfg.blobStore.Delete(ctx, key); err != nil {
+				log.Printf("Error deleting media %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// sweepUnattachedMedia deletes uploads that were never attached to a chirp.
+func (cfg *apiConfig) sweepUnattachedMedia(ctx context.Context) error {
+	dbMedia, err := cfg.dbQueries.DeleteUnattachedMediaFiles(ctx, time.Now().UTC().Add(-unattachedMediaRetention))
+	if err != nil {
+		return err
+	}
+	cfg.deleteMediaBlobs(ctx, dbMedia)
+	if len(dbMedia) > 0 {
+		log.Printf("Deleted %d unattached uploads", len(dbMedia))
+	}
+	return nil
+}
+
+// runMediaSweep periodically deletes unattached uploads.
+func (cfg *apiConfig) runMediaSweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := cfg.sweepUnattachedMedia(context.Background()); err != nil {
+			log.Printf("Error deleting unattached uploads: %v", err)
+		}
+	}
+}
+
+func (cfg *apiConfig) handlerUploadMedia(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+	ent, err := cfg.userEntitlements(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+	if !cfg.allowWrite(w, userID, ent) {
+		return
+	}
+
+	data, err := readUpload(w, r)
+	if err != nil {
+		status, message := uploadErrorStatus(err)
+		utils.RespondWithError(w, status, message)
+		return
+	}
+
+	dbMedia, err := cfg.storeImage(r.Context(), userID, data)
+	if err != nil {
+		status, message := uploadErrorStatus(err)
+		utils.RespondWithError(w, status, message)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, mediaFromDB(dbMedia))
+}
+
+func (cfg *apiConfig) handlerUploadAvatar(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	data, err := readUpload(w, r)
+	if err != nil {
+		status, message := uploadErrorStatus(err)
+		utils.RespondWithError(w, status, message)
+		return
+	}
+
+	dbMedia, err := cfg.storeImage(r.Context(), userID, data)
+	if err != nil {
+		status, message := uploadErrorStatus(err)
+		utils.RespondWithError(w, status, message)
+		return
+	}
+
+	dbUser, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User could not be found")
+		return
+	}
+	dbUser, err = cfg.dbQueries.UpdateUserProfile(r.Context(), database.UpdateUserProfileParams{
+		ID:          userID,
+		Handle:      dbUser.Handle,
+		DisplayName: dbUser.DisplayName,
+		Bio:         dbUser.Bio,
+		AvatarUrl:   mediaFromDB(dbMedia).URL,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not update avatar")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, userFromDB(dbUser))
+}
+
+func (cfg *apiConfig) handlerGetMedia(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, false)
+}
+
+func (cfg *apiConfig) handlerGetMediaThumbnail(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, true)
+}
+
+// canViewMedia applies the visibility rules of the chirp the media is in.
+// Media outside of chirps, such as avatars, is hidden once its uploader is
+// banned.
+func (cfg *apiConfig) canViewMedia(ctx context.Context, dbMedia database.MediaFile, viewerID uuid.UUID) (bool, error) {
+	if !dbMedia.ChirpID.Valid {
+		uploader, err := cfg.dbQueries.GetUserAccountStatus(ctx, dbMedia.UserID)
+		if err != nil {
+			return false, err
+		}
+		return !uploader.BannedAt.Valid, nil
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirp(ctx, dbMedia.ChirpID.UUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return cfg.canViewChirp(ctx, dbChirp, viewerID)
+}
+
+func (cfg *apiConfig) serveMedia(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	mediaID, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid media ID")
+		return
+	}
+
+	viewerID, err := cfg.viewer(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbMedia, err := cfg.dbQueries.GetMediaFile(r.Context(), mediaID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Media could not be found")
+		return
+	}
+	visible, err := cfg.canViewMedia(r.Context(), dbMedia, viewerID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not read media")
+		return
+	}
+	if !visible {
+		utils.RespondWithError(w, http.StatusNotFound, "Media could not be found")
+		return
+	}
+
+	key := dbMedia.StorageKey
+	if thumbnail {
+		key = dbMedia.ThumbnailKey
+	}
+	blob, err := cfg.blobStore.Get(r.Context(), key)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		utils.RespondWithError(w, http.StatusNotFound, "Media could not be found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not read media")
+		return
+	}
+	defer blob.Close()
+
+	// Stored media never changes, a new upload always gets a new ID. It can
+	// still be hidden, and whether it is depends on the viewer, so it is
+	// only cached privately and for a while.
+	w.Header().Set("Content-Type", dbMedia.ContentType)
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, blob)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/blobstore"
+	"github.com/tsyrdev/chirpy/internal/database"
+)
+
+// createTestMedia stores a placeholder upload by userID. The blobs aren't
+// real images, which is enough for tests that don't decode them.
+func createTestMedia(t *testing.T, cfg *apiConfig, userID uuid.UUID) database.MediaFile {
+	t.Helper()
+	ctx := context.Background()
+	id := uuid.New()
+	key := "media/" + id.String() + ".png"
+	thumbnailKey := "media/" + id.String() + "_thumb.png"
+	for _, k := range []string{key, thumbnailKey} {
+		if err := cfg.blobStore.Put(ctx, k, "image/png", []byte("png")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dbMedia, err := cfg.dbQueries.CreateMediaFile(ctx, database.CreateMediaFileParams{
+		ID:           id,
+		UserID:       userID,
+		ContentType:  "image/png",
+		SizeBytes:    3,
+		Width:        1,
+		Height:       1,
+		StorageKey:   key,
+		ThumbnailKey: thumbnailKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dbMedia
+}
+
+func TestMediaOfHiddenChirpIsNotServed(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+	author, _ := createTestUser(t, cfg)
+	_, token := createTestUser(t, cfg)
+	dbChirp := createTestChirp(t, cfg, author.ID, "look")
+	dbMedia := createTestMedia(t, cfg, author.ID)
+
+	_, err := cfg.dbQueries.AttachMediaFile(ctx, database.AttachMediaFileParams{
+		ChirpID: uuid.NullUUID{UUID: dbChirp.ID, Valid: true},
+		ID:      dbMedia.ID,
+		UserID:  author.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/api/media/" + dbMedia.ID.String()
+	if status := serveTestRequest(cfg.handlerGetMedia, http.MethodGet, "/api/media/{mediaID}", path, token, ""); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+
+	if _, err := cfg.dbQueries.HideChirp(ctx, dbChirp.ID); err != nil {
+		t.Fatal(err)
+	}
+	if status := serveTestRequest(cfg.handlerGetMedia, http.MethodGet, "/api/media/{mediaID}", path, token, ""); status != http.StatusNotFound {
+		t.Errorf("expected 404 for media in a hidden chirp, got %d", status)
+	}
+}
+
+func TestSweepDeletesOldUnattachedMedia(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+	dbUser, _ := createTestUser(t, cfg)
+	dbMedia := createTestMedia(t, cfg, dbUser.ID)
+
+	if err := cfg.sweepUnattachedMedia(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.dbQueries.GetMediaFile(ctx, dbMedia.ID); err != nil {
+		t.Fatalf("expected a new upload to be kept: %v", err)
+	}
+
+	_, err := cfg.db.ExecContext(ctx, "UPDATE media_files SET created_at = $2 WHERE id = $1", dbMedia.ID, time.Now().UTC().Add(-2*unattachedMediaRetention))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.sweepUnattachedMedia(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.dbQueries.GetMediaFile(ctx, dbMedia.ID); err == nil {
+		t.Error("expected the old upload to be deleted")
+	}
+	if _, err := cfg.blobStore.Get(ctx, dbMedia.StorageKey); !errors.Is(err, blobstore.ErrNotFound) {
+		t.Errorf("expected the upload's file to be deleted, got %v", err)
+	}
+}
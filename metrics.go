@@ -8,7 +8,7 @@ import (
 func (ac *apiConfig) handlerGetMetrics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "text/html")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(fmt.Sprint(`
+	w.Write([]byte(fmt.Sprintf(`
 <html> 
 <body>
 	<h1>Welcome, Chirpy Admin</h1>
@@ -0,0 +1,549 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+var reportReasons = []string{"spam", "harassment", "hate", "violence", "sexual", "misinformation", "other"}
+
+const maxReportDetailsLength = 1000
+
+// Actions a moderator can take on a reported chirp.
+const (
+	moderationDismiss = "dismiss"
+	moderationHide    = "hide"
+	moderationUnhide  = "unhide"
+	moderationDelete  = "delete"
+	moderationWarn    = "warn"
+	moderationSuspend = "suspend"
+)
+
+// Actions a moderator can take on an account.
+const (
+	moderationBan       = "ban"
+	moderationUnban     = "unban"
+	moderationUnsuspend = "unsuspend"
+)
+
+// eventAccountDisabled is published when a user is banned or suspended so
+// that their open streams and WebSocket connections are closed.
+const eventAccountDisabled = "account.disabled"
+
+var moderationActions = []string{moderationDismiss, moderationHide, moderationUnhide, moderationDelete, moderationWarn, moderationSuspend}
+
+// Statuses of a handled report. New reports are "open".
+const (
+	reportResolved  = "resolved"
+	reportDismissed = "dismissed"
+)
+
+// hiddenChirpNotice is shown to authors on their chirps that were hidden.
+const hiddenChirpNotice = "This chirp was hidden by a moderator and is only visible to you."
+
+// notificationWarning tells an author that a moderator warned them about a
+// chirp. Unlike other notification types it can't be turned off.
+const notificationWarning = "warning"
+
+type Report struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	ReporterID uuid.UUID `json:"reporter_id"`
+	Reason     string    `json:"reason"`
+	Details    string    `json:"details"`
+	Status     string    `json:"status"`
+}
+
+func reportFromDB(dbReport database.Report) Report {
+	return Report{
+		ID:         dbReport.ID,
+		CreatedAt:  dbReport.CreatedAt,
+		ChirpID:    dbReport.ChirpID,
+		ReporterID: dbReport.ReporterID,
+		Reason:     dbReport.Reason,
+		Details:    dbReport.Details,
+		Status:     dbReport.Status,
+	}
+}
+
+// ReportedChirp is an entry in the moderation queue.
+type ReportedChirp struct {
+	Chirp           Chirp     `json:"chirp"`
+	ReportCount     int64     `json:"report_count"`
+	FirstReportedAt time.Time `json:"first_reported_at"`
+	Reports         []Report  `json:"reports"`
+}
+
+type ModerationAction struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ModeratorID    *uuid.UUID `json:"moderator_id"`
+	Action         string     `json:"action"`
+	ChirpID        *uuid.UUID `json:"chirp_id"`
+	UserID         *uuid.UUID `json:"user_id"`
+	Reason         string     `json:"reason"`
+	SuspendedUntil *time.Time `json:"suspended_until"`
+}
+
+func moderationActionFromDB(dbAction database.ModerationAction) ModerationAction {
+	return ModerationAction{
+		ID:             dbAction.ID,
+		CreatedAt:      dbAction.CreatedAt,
+		ModeratorID:    nullUUIDPtr(dbAction.ModeratorID),
+		Action:         dbAction.Action,
+		ChirpID:        nullUUIDPtr(dbAction.ChirpID),
+		UserID:         nullUUIDPtr(dbAction.UserID),
+		Reason:         dbAction.Reason,
+		SuspendedUntil: nullTimePtr(dbAction.SuspendedUntil),
+	}
+}
+
+// chirpVisible reports whether a chirp can be shown to the viewer. Hidden
+// and scheduled chirps are only shown to their author.
+func chirpVisible(dbChirp database.Chirp, viewerID uuid.UUID) bool {
+	return (!dbChirp.HiddenAt.Valid && !dbChirp.ScheduledFor.Valid) || dbChirp.UserID == viewerID
+}
+
+func (cfg *apiConfig) handlerReportChirp(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	var params struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if !slices.Contains(reportReasons, params.Reason) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Unknown report reason: "+params.Reason)
+		return
+	}
+	if len(params.Details) > maxReportDetailsLength {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Details can be at most %d characters", maxReportDetailsLength))
+		return
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirp(r.Context(), chirpID)
+	if err != nil || !chirpVisible(dbChirp, userID) {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp could not be found")
+		return
+	}
+	if dbChirp.UserID == userID {
+		utils.RespondWithError(w, http.StatusBadRequest, "Users can't report their own chirps")
+		return
+	}
+
+	dbReport, err := cfg.dbQueries.CreateReport(r.Context(), database.CreateReportParams{
+		ChirpID:    chirpID,
+		ReporterID: userID,
+		Reason:     params.Reason,
+		Details:    params.Details,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusConflict, "Chirp has already been reported")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not report chirp")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, reportFromDB(dbReport))
+}
+
+func (cfg *apiConfig) handlerGetReportQueue(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := cfg.requireAdmin(r); err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	_, limit, err := parsePageParams(r, defaultMessagesLimit, maxMessagesLimit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbQueue, err := cfg.dbQueries.GetReportQueue(r.Context(), limit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get reports")
+		return
+	}
+	chirpIDs := make([]uuid.UUID, 0, len(dbQueue))
+	for _, row := range dbQueue {
+		chirpIDs = append(chirpIDs, row.ChirpID)
+	}
+
+	dbChirps, err := cfg.dbQueries.GetChirpsByIDs(r.Context(), chirpIDs)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get reports")
+		return
+	}
+	chirps, err := cfg.chirpsFromDB(r.Context(), dbChirps)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get reports")
+		return
+	}
+	dbReports, err := cfg.dbQueries.GetOpenReports(r.Context(), chirpIDs)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get reports")
+		return
+	}
+
+	queue := make([]ReportedChirp, 0, len(dbQueue))
+	for _, row := range dbQueue {
+		idx := slices.IndexFunc(chirps, func(chirp Chirp) bool { return chirp.ID == row.ChirpID })
+		if idx < 0 {
+			continue
+		}
+		entry := ReportedChirp{
+			Chirp:           chirps[idx],
+			ReportCount:     row.ReportCount,
+			FirstReportedAt: row.FirstReportedAt,
+			Reports:         []Report{},
+		}
+		for _, dbReport := range dbReports {
+			if dbReport.ChirpID == row.ChirpID {
+				entry.Reports = append(entry.Reports, reportFromDB(dbReport))
+			}
+		}
+		queue = append(queue, entry)
+	}
+	utils.RespondWithJSON(w, http.StatusOK, queue)
+}
+
+func (cfg *apiConfig) handlerModerateChirp(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	moderatorID, err := cfg.requireAdmin(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	var params struct {
+		Action        string `json:"action"`
+		Reason        string `json:"reason"`
+		DurationHours int    `json:"duration_hours"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if !slices.Contains(moderationActions, params.Action) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Unknown moderation action: "+params.Action)
+		return
+	}
+	var suspendedUntil sql.NullTime
+	if params.Action == moderationSuspend {
+		if params.DurationHours < 1 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Suspensions need a duration_hours of at least 1")
+			return
+		}
+		suspendedUntil = sql.NullTime{
+			Time:  time.Now().UTC().Add(time.Duration(params.DurationHours) * time.Hour),
+			Valid: true,
+		}
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp could not be found")
+		return
+	}
+
+	// The action, its audit record and the resolution of the chirp's
+	// reports are committed together.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not moderate the chirp")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	dbAction, err := qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ModeratorID:    uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:         params.Action,
+		ChirpID:        uuid.NullUUID{UUID: chirpID, Valid: true},
+		UserID:         uuid.NullUUID{UUID: dbChirp.UserID, Valid: true},
+		Reason:         params.Reason,
+		SuspendedUntil: suspendedUntil,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not moderate the chirp")
+		return
+	}
+
+	status := reportResolved
+	if params.Action == moderationDismiss {
+		status = reportDismissed
+	}
+	_, err = qtx.ResolveReports(r.Context(), database.ResolveReportsParams{
+		ChirpID: chirpID,
+		Status:  status,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not moderate the chirp")
+		return
+	}
+
+	switch params.Action {
+	case moderationHide:
+		_, err = qtx.HideChirp(r.Context(), chirpID)
+	case moderationUnhide:
+		_, err = qtx.UnhideChirp(r.Context(), chirpID)
+	case moderationDelete:
+		err = qtx.DeleteChirp(r.Context(), database.DeleteChirpParams{
+			ID:        chirpID,
+			DeletedBy: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		})
+	case moderationSuspend:
+		err = qtx.SetUserSuspension(r.Context(), database.SetUserSuspensionParams{
+			ID:             dbChirp.UserID,
+			SuspendedUntil: suspendedUntil,
+		})
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not moderate the chirp")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not moderate the chirp")
+		return
+	}
+
+	switch params.Action {
+	case moderationHide, moderationDelete:
+		// Live feeds drop hidden chirps the same way as deleted ones.
+		// Scheduled chirps haven't been seen yet.
+		if dbChirp.ScheduledFor.Valid {
+			break
+		}
+		cfg.publishEvent(r.Context(), eventChirpDeleted, dbChirp.UserID, map[string]uuid.UUID{
+			"id":      chirpID,
+			"user_id": dbChirp.UserID,
+		})
+	case moderationSuspend:
+		cfg.publishEvent(r.Context(), eventAccountDisabled, dbChirp.UserID, struct{}{})
+	case moderationWarn:
+		cfg.notifyWarning(r.Context(), dbChirp.UserID, moderatorID, chirpID)
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, moderationActionFromDB(dbAction))
+}
+
+// notifyWarning tells an author that a moderator warned them about a chirp.
+// Unlike notify it ignores blocks, so authors can't avoid warnings by
+// blocking the moderator.
+func (cfg *apiConfig) notifyWarning(ctx context.Context, userID, moderatorID, chirpID uuid.UUID) {
+	dbNotification, err := cfg.dbQueries.CreateWarningNotification(ctx, database.CreateWarningNotificationParams{
+		UserID:  userID,
+		ActorID: moderatorID,
+		ChirpID: uuid.NullUUID{UUID: chirpID, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error creating %s notification: %v", notificationWarning, err)
+		return
+	}
+	cfg.pushNotification(ctx, dbNotification)
+}
+
+func (cfg *apiConfig) handlerGetModerationActions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := cfg.requireAdmin(r); err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	before, limit, err := parsePageParams(r, defaultMessagesLimit, maxMessagesLimit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	params := database.GetModerationActionsParams{
+		Before:     before,
+		MaxResults: limit,
+	}
+	query := r.URL.Query()
+	if value := query.Get("user_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+		params.UserID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	if value := query.Get("chirp_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+			return
+		}
+		params.ChirpID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	dbActions, err := cfg.dbQueries.GetModerationActions(r.Context(), params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get moderation actions")
+		return
+	}
+
+	actions := make([]ModerationAction, 0, len(dbActions))
+	for _, dbAction := range dbActions {
+		actions = append(actions, moderationActionFromDB(dbAction))
+	}
+	utils.RespondWithJSON(w, http.StatusOK, actions)
+}
+
+// moderateUser applies an account-level action to the user in the request
+// path and records it in the audit trail.
+func (cfg *apiConfig) moderateUser(w http.ResponseWriter, r *http.Request, action string, apply func(qtx *database.Queries, userID uuid.UUID, suspendedUntil sql.NullTime) error) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	moderatorID, err := cfg.requireAdmin(r)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if userID == moderatorID {
+		utils.RespondWithError(w, http.StatusBadRequest, "Admins can't moderate their own account")
+		return
+	}
+	if _, err := cfg.dbQueries.GetUserByID(r.Context(), userID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User could not be found")
+		return
+	}
+
+	// Lifting a ban or suspension needs no body.
+	var params struct {
+		Reason        string `json:"reason"`
+		DurationHours int    `json:"duration_hours"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	var suspendedUntil sql.NullTime
+	if action == moderationSuspend {
+		if params.DurationHours < 1 {
+			utils.RespondWithError(w, http.StatusBadRequest, "Suspensions need a duration_hours of at least 1")
+			return
+		}
+		suspendedUntil = sql.NullTime{
+			Time:  time.Now().UTC().Add(time.Duration(params.DurationHours) * time.Hour),
+			Valid: true,
+		}
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not moderate the user")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	if err := apply(qtx, userID, suspendedUntil); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not moderate the user")
+		return
+	}
+	dbAction, err := qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ModeratorID:    uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:         action,
+		UserID:         uuid.NullUUID{UUID: userID, Valid: true},
+		Reason:         params.Reason,
+		SuspendedUntil: suspendedUntil,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not moderate the user")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not moderate the user")
+		return
+	}
+
+	if action == moderationBan || action == moderationSuspend {
+		cfg.publishEvent(r.Context(), eventAccountDisabled, userID, struct{}{})
+	}
+	utils.RespondWithJSON(w, http.StatusOK, moderationActionFromDB(dbAction))
+}
+
+func (cfg *apiConfig) handlerBanUser(w http.ResponseWriter, r *http.Request) {
+	cfg.moderateUser(w, r, moderationBan, func(qtx *database.Queries, userID uuid.UUID, _ sql.NullTime) error {
+		err := qtx.SetUserBan(r.Context(), database.SetUserBanParams{
+			ID:       userID,
+			BannedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		// Banned users have to log in again if they are ever unbanned.
+		return qtx.DeleteUserRefreshTokens(r.Context(), userID)
+	})
+}
+
+func (cfg *apiConfig) handlerUnbanUser(w http.ResponseWriter, r *http.Request) {
+	cfg.moderateUser(w, r, moderationUnban, func(qtx *database.Queries, userID uuid.UUID, _ sql.NullTime) error {
+		return qtx.SetUserBan(r.Context(), database.SetUserBanParams{ID: userID})
+	})
+}
+
+func (cfg *apiConfig) handlerSuspendUser(w http.ResponseWriter, r *http.Request) {
+	cfg.moderateUser(w, r, moderationSuspend, func(qtx *database.Queries, userID uuid.UUID, suspendedUntil sql.NullTime) error {
+		return qtx.SetUserSuspension(r.Context(), database.SetUserSuspensionParams{
+			ID:             userID,
+			SuspendedUntil: suspendedUntil,
+		})
+	})
+}
+
+func (cfg *apiConfig) handlerUnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	cfg.moderateUser(w, r, moderationUnsuspend, func(qtx *database.Queries, userID uuid.UUID, _ sql.NullTime) error {
+		return qtx.SetUserSuspension(r.Context(), database.SetUserSuspensionParams{ID: userID})
+	})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/internal/events"
+)
+
+func TestWarningReachesAuthorWhoBlockedModerator(t *testing.T) {
+	cfg := newTestConfig(t)
+	moderator, token := createTestUser(t, cfg)
+	author, _ := createTestUser(t, cfg)
+	makeTestAdmin(t, cfg, moderator.ID)
+
+	err := cfg.dbQueries.BlockUser(context.Background(), database.BlockUserParams{
+		BlockerID: author.ID,
+		BlockedID: moderator.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbChirp := createTestChirp(t, cfg, author.ID, "warn me")
+
+	path := "/admin/chirps/" + dbChirp.ID.String() + "/moderate"
+	status := serveTestRequest(cfg.handlerModerateChirp, http.MethodPost, "/admin/chirps/{chirpID}/moderate", path, token, `{"action":"warn","reason":"test"}`)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+
+	if n := countNotifications(t, cfg, author.ID, notificationWarning); n != 1 {
+		t.Errorf("expected 1 warning notification, got %d", n)
+	}
+}
+
+func TestHideChirpRemovesItFromLiveFeeds(t *testing.T) {
+	cfg := newTestConfig(t)
+	moderator, token := createTestUser(t, cfg)
+	author, _ := createTestUser(t, cfg)
+	makeTestAdmin(t, cfg, moderator.ID)
+	dbChirp := createTestChirp(t, cfg, author.ID, "hide me")
+
+	sub, _ := cfg.events.Subscribe(func(event events.Event) bool {
+		return event.UserID == author.ID
+	}, 0)
+	defer sub.Close()
+
+	path := "/admin/chirps/" + dbChirp.ID.String() + "/moderate"
+	status := serveTestRequest(cfg.handlerModerateChirp, http.MethodPost, "/admin/chirps/{chirpID}/moderate", path, token, `{"action":"hide","reason":"test"}`)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+
+	select {
+	case event := <-sub.C:
+		if event.Type != eventChirpDeleted {
+			t.Errorf("expected a %s event, got %s", eventChirpDeleted, event.Type)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("no event was published for the hidden chirp")
+	}
+}
+
+func TestSuspendedUserCannotWrite(t *testing.T) {
+	cfg := newTestConfig(t)
+	moderator, adminToken := createTestUser(t, cfg)
+	user, token := createTestUser(t, cfg)
+	makeTestAdmin(t, cfg, moderator.ID)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/chirps", cfg.handlerCreateChirp)
+	handler := cfg.middlewareAccountStatus(mux)
+	createChirp := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/api/chirps", strings.NewReader(`{"body":"still here"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	path := "/admin/users/" + user.ID.String() + "/suspend"
+	status := serveTestRequest(cfg.handlerSuspendUser, http.MethodPost, "/admin/users/{userID}/suspend", path, adminToken, `{"reason":"test","duration_hours":1}`)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if status := createChirp(); status != http.StatusForbidden {
+		t.Errorf("expected 403 while suspended, got %d", status)
+	}
+
+	status = serveTestRequest(cfg.handlerUnsuspendUser, http.MethodDelete, "/admin/users/{userID}/suspend", path, adminToken, "")
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if status := createChirp(); status != http.StatusCreated {
+		t.Errorf("expected 201 after the suspension is lifted, got %d", status)
+	}
+}
+
+func TestBannedUsersChirpsAreHidden(t *testing.T) {
+	cfg := newTestConfig(t)
+	moderator, adminToken := createTestUser(t, cfg)
+	author, _ := createTestUser(t, cfg)
+	makeTestAdmin(t, cfg, moderator.ID)
+	dbChirp := createTestChirp(t, cfg, author.ID, "banned soon")
+
+	path := "/admin/users/" + author.ID.String() + "/ban"
+	status := serveTestRequest(cfg.handlerBanUser, http.MethodPost, "/admin/users/{userID}/ban", path, adminToken, `{"reason":"test"}`)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+
+	if err := cfg.checkAccountStatus(context.Background(), author.ID); !errors.Is(err, errAccountBanned) {
+		t.Errorf("expected errAccountBanned, got %v", err)
+	}
+	status = serveTestRequest(cfg.handlerGetChirp, http.MethodGet, "/api/chirps/{chirpID}", "/api/chirps/"+dbChirp.ID.String(), "", "")
+	if status != http.StatusNotFound {
+		t.Errorf("expected 404 for a banned user's chirp, got %d", status)
+	}
+}
+
+func TestAdminsCannotModerateThemselves(t *testing.T) {
+	cfg := newTestConfig(t)
+	moderator, adminToken := createTestUser(t, cfg)
+	makeTestAdmin(t, cfg, moderator.ID)
+
+	path := "/admin/users/" + moderator.ID.String() + "/ban"
+	status := serveTestRequest(cfg.handlerBanUser, http.MethodPost, "/admin/users/{userID}/ban", path, adminToken, `{"reason":"test"}`)
+	if status != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", status)
+	}
+}
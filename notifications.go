@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/auth"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+const (
+	notificationMention = "mention"
+	notificationReply   = "reply"
+	notificationLike    = "like"
+	notificationFollow  = "follow"
+)
+
+var notificationTypes = []string{notificationMention, notificationReply, notificationLike, notificationFollow}
+
+const (
+	defaultNotificationsLimit = 20
+	maxNotificationsLimit     = 100
+)
+
+var mentionPattern = regexp.MustCompile(`@([A-Za-z0-9_]{3,15})\b`)
+
+type Notification struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Type      string     `json:"type"`
+	Actor     *Author    `json:"actor"`
+	ChirpID   *uuid.UUID `json:"chirp_id"`
+	ReadAt    *time.Time `json:"read_at"`
+}
+
+// notificationsFromDB converts database notifications into API responses,
+// embedding the profile of the user who caused each one.
+func (cfg *apiConfig) notificationsFromDB(ctx context.Context, dbNotifications []database.Notification) ([]Notification, error) {
+	actorIDs := make([]uuid.UUID, 0, len(dbNotifications))
+	for _, dbNotification := range dbNotifications {
+		if !slices.Contains(actorIDs, dbNotification.ActorID) {
+			actorIDs = append(actorIDs, dbNotification.ActorID)
+		}
+	}
+	actors, err := cfg.authorsByID(ctx, actorIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := make([]Notification, 0, len(dbNotifications))
+	for _, dbNotification := range dbNotifications {
+		notification := Notification{
+			ID:        dbNotification.ID,
+			CreatedAt: dbNotification.CreatedAt,
+			Type:      dbNotification.Type,
+			Actor:     actors[dbNotification.ActorID],
+			ReadAt:    nullTimePtr(dbNotification.ReadAt),
+		}
+		if dbNotification.ChirpID.Valid {
+			notification.ChirpID = &dbNotification.ChirpID.UUID
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, nil
+}
+
+// mentionedHandles returns the handles @mentioned in a chirp.
+func mentionedHandles(body string) []string {
+	var handles []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		handle := strings.ToLower(match[1])
+		if !slices.Contains(handles, handle) {
+			handles = append(handles, handle)
+		}
+	}
+	return handles
+}
+
+// notify records a notification for userID about something actorID did and
+// pushes it to the user's live connections. Users aren't notified about
+// their own actions or about types they turned off. Failures are logged
+// rather than failing the request that caused the notification.
+func (cfg *apiConfig) notify(ctx context.Context, userID, actorID uuid.UUID, notificationType string, chirpID uuid.NullUUID) {
+	dbNotification, err := cfg.dbQueries.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  userID,
+		ActorID: actorID,
+		Type:    notificationType,
+		ChirpID: chirpID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating %s notification: %v", notificationType, err)
+		return
+	}
+	cfg.pushNotification(ctx, dbNotification)
+}
+
+// pushNotification sends a newly created notification to the user's live
+// connections.
+func (cfg *apiConfig) pushNotification(ctx context.Context, dbNotification database.Notification) {
+	notifications, err := cfg.notificationsFromDB(ctx, []database.Notification{dbNotification})
+	if err != nil {
+		log.Printf("Error loading %s notification: %v", dbNotification.Type, err)
+		return
+	}
+	cfg.publishEvent(ctx, eventNotificationCreated, dbNotification.UserID, notifications[0])
+}
+
+// notifyChirp notifies the author of the chirp being replied to and every
+// mentioned user about a new chirp.
+func (cfg *apiConfig) notifyChirp(ctx context.Context, dbChirp database.Chirp) {
+	chirpID := uuid.NullUUID{UUID: dbChirp.ID, Valid: true}
+
+	var parentAuthor uuid.UUID
+	if dbChirp.InReplyTo.Valid {
+		parent, err := cfg.dbQueries.GetChirp(ctx, dbChirp.InReplyTo.UUID)
+		if err == nil {
+			parentAuthor = parent.UserID
+			cfg.notify(ctx, parent.UserID, dbChirp.UserID, notificationReply, chirpID)
+		}
+	}
+
+	handles := mentionedHandles(dbChirp.Body)
+	if len(handles) == 0 {
+		return
+	}
+	mentioned, err := cfg.dbQueries.GetUserIDsByHandles(ctx, handles)
+	if err != nil {
+		log.Printf("Error looking up mentioned users: %v", err)
+		return
+	}
+	for _, userID := range mentioned {
+		// The reply notification already covers the parent's author.
+		if userID == parentAuthor {
+			continue
+		}
+		cfg.notify(ctx, userID, dbChirp.UserID, notificationMention, chirpID)
+	}
+}
+
+func (cfg *apiConfig) handlerGetNotifications(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	before, limit, err := parsePageParams(r, defaultNotificationsLimit, maxNotificationsLimit)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	params := database.GetNotificationsParams{
+		UserID:     userID,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		Before:     before,
+		MaxResults: limit,
+	}
+
+	dbNotifications, err := cfg.dbQueries.GetNotifications(r.Context(), params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get notifications")
+		return
+	}
+	unread, err := cfg.dbQueries.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not count unread notifications")
+		return
+	}
+	notifications, err := cfg.notificationsFromDB(r.Context(), dbNotifications)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get notifications")
+		return
+	}
+
+	// next_before is the cursor for the following page, if there may be one.
+	response := struct {
+		UnreadCount   int64          `json:"unread_count"`
+		Notifications []Notification `json:"notifications"`
+		NextBefore    *time.Time     `json:"next_before"`
+	}{
+		UnreadCount:   unread,
+		Notifications: notifications,
+	}
+	if len(notifications) == int(params.MaxResults) {
+		response.NextBefore = &notifications[len(notifications)-1].CreatedAt
+	}
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerMarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	notificationID, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid notification ID")
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	marked, err := cfg.dbQueries.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ID:     notificationID,
+		UserID: userID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not mark notification as read")
+		return
+	}
+	if marked == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Notification could not be found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerMarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	if err := cfg.dbQueries.MarkAllNotificationsRead(r.Context(), userID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not mark notifications as read")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notificationPreferences returns whether each notification type is on.
+// Types are on unless the user turned them off.
+func (cfg *apiConfig) notificationPreferences(ctx context.Context, userID uuid.UUID) (map[string]bool, error) {
+	dbPrefs, err := cfg.dbQueries.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs := make(map[string]bool, len(notificationTypes))
+	for _, notificationType := range notificationTypes {
+		prefs[notificationType] = true
+	}
+	for _, dbPref := range dbPrefs {
+		prefs[dbPref.Type] = dbPref.Enabled
+	}
+	return prefs, nil
+}
+
+func (cfg *apiConfig) handlerGetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	prefs, err := cfg.notificationPreferences(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get notification preferences")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, prefs)
+}
+
+func (cfg *apiConfig) handlerUpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	// Types left out of the request keep their current setting.
+	var params map[string]bool
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	for notificationType := range params {
+		if !slices.Contains(notificationTypes, notificationType) {
+			utils.RespondWithError(w, http.StatusBadRequest, "Unknown notification type: "+notificationType)
+			return
+		}
+	}
+
+	for notificationType, enabled := range params {
+		err := cfg.dbQueries.SetNotificationPreference(r.Context(), database.SetNotificationPreferenceParams{
+			UserID:  userID,
+			Type:    notificationType,
+			Enabled: enabled,
+		})
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Could not update notification preferences")
+			return
+		}
+	}
+
+	prefs, err := cfg.notificationPreferences(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get notification preferences")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, prefs)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+)
+
+func TestReplyNotifiesParentAuthorAndMentions(t *testing.T) {
+	cfg := newTestConfig(t)
+	author, _ := createTestUser(t, cfg)
+	replier, _ := createTestUser(t, cfg)
+	mentioned, _ := createTestUser(t, cfg)
+	parent := createTestChirp(t, cfg, author.ID, "first")
+
+	reply, err := cfg.dbQueries.CreateChirps(context.Background(), database.CreateChirpsParams{
+		Body:      "@" + author.Handle.String + " @" + mentioned.Handle.String + " agreed",
+		UserID:    replier.ID,
+		InReplyTo: uuid.NullUUID{UUID: parent.ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.notifyChirp(context.Background(), reply)
+
+	if n := countNotifications(t, cfg, author.ID, notificationReply); n != 1 {
+		t.Errorf("expected 1 reply notification, got %d", n)
+	}
+	// The parent's author is mentioned too but only gets the reply.
+	if n := countNotifications(t, cfg, author.ID, notificationMention); n != 0 {
+		t.Errorf("expected no mention notification for the parent's author, got %d", n)
+	}
+	if n := countNotifications(t, cfg, mentioned.ID, notificationMention); n != 1 {
+		t.Errorf("expected 1 mention notification, got %d", n)
+	}
+	if n := countNotifications(t, cfg, replier.ID, notificationMention); n != 0 {
+		t.Errorf("expected no notifications for the replier, got %d", n)
+	}
+}
+
+func TestDisabledNotificationTypeIsNotCreated(t *testing.T) {
+	cfg := newTestConfig(t)
+	user, token := createTestUser(t, cfg)
+	actor, _ := createTestUser(t, cfg)
+
+	status := serveTestRequest(cfg.handlerUpdateNotificationPreferences, http.MethodPut, "/api/notifications/preferences", "/api/notifications/preferences", token, `{"follow":false}`)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	cfg.notify(context.Background(), user.ID, actor.ID, notificationFollow, uuid.NullUUID{})
+
+	if n := countNotifications(t, cfg, user.ID, notificationFollow); n != 0 {
+		t.Errorf("expected no follow notification, got %d", n)
+	}
+
+	status = serveTestRequest(cfg.handlerUpdateNotificationPreferences, http.MethodPut, "/api/notifications/preferences", "/api/notifications/preferences", token, `{"shout":false}`)
+	if status != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown type, got %d", status)
+	}
+}
+
+func TestMarkNotificationRead(t *testing.T) {
+	cfg := newTestConfig(t)
+	user, token := createTestUser(t, cfg)
+	actor, _ := createTestUser(t, cfg)
+	cfg.notify(context.Background(), user.ID, actor.ID, notificationFollow, uuid.NullUUID{})
+	cfg.notify(context.Background(), user.ID, actor.ID, notificationLike, uuid.NullUUID{})
+
+	var page struct {
+		UnreadCount   int64          `json:"unread_count"`
+		Notifications []Notification `json:"notifications"`
+	}
+	getPage := func() {
+		t.Helper()
+		rec := serveTestRecorder(cfg.handlerGetNotifications, http.MethodGet, "/api/notifications", "/api/notifications", token, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	getPage()
+	if page.UnreadCount != 2 || len(page.Notifications) != 2 {
+		t.Fatalf("expected 2 unread notifications, got %d of %d", page.UnreadCount, len(page.Notifications))
+	}
+
+	path := "/api/notifications/" + page.Notifications[0].ID.String() + "/read"
+	status := serveTestRequest(cfg.handlerMarkNotificationRead, http.MethodPost, "/api/notifications/{notificationID}/read", path, token, "")
+	if status != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", status)
+	}
+	getPage()
+	if page.UnreadCount != 1 {
+		t.Errorf("expected 1 unread notification, got %d", page.UnreadCount)
+	}
+
+	// Someone else's notification is not found.
+	_, otherToken := createTestUser(t, cfg)
+	status = serveTestRequest(cfg.handlerMarkNotificationRead, http.MethodPost, "/api/notifications/{notificationID}/read", path, otherToken, "")
+	if status != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", status)
+	}
+
+	status = serveTestRequest(cfg.handlerMarkAllNotificationsRead, http.MethodPost, "/api/notifications/read", "/api/notifications/read", token, "")
+	if status != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", status)
+	}
+	getPage()
+	if page.UnreadCount != 0 {
+		t.Errorf("expected no unread notifications, got %d", page.UnreadCount)
+	}
+}
+
+func TestMentionedHandles(t *testing.T) {
+	got := mentionedHandles("hi @Alice and @bob, @alice again, not @x")
+	want := []string{"alice", "bob"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/auth"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+const polkaSignatureTolerance = 5 * time.Minute
+
+var errWebhookReplay = errors.New("webhook delivery has already been processed")
+
+// parsePolkaKeys splits POLKA_KEY on commas. During a rotation both the old
+// and the new key are listed.
+func parsePolkaKeys(value string) []string {
+	var keys []string
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// verifyPolkaRequest authenticates a Polka webhook delivery. Signed
+// deliveries carry an HMAC of the raw body and a timestamp; the matching
+// signature is returned so it can be checked for replays. Deliveries
+// without signature headers fall back to the static "ApiKey" header unless
+// POLKA_REQUIRE_SIGNATURE is set.
+func (cfg *apiConfig) verifyPolkaRequest(r *http.Request, body []byte) (string, error) {
+	signature, err := auth.VerifyWebhookSignature(r.Header, body, cfg.polkaKeys, polkaSignatureTolerance, time.Now())
+	if errors.Is(err, auth.ErrMissingSignature) && !cfg.requirePolkaSignature {
+		apiKey, err := auth.GetAPIKey(r.Header)
+		if err != nil {
+			return "", err
+		}
+		if !auth.CheckStaticKey(apiKey, cfg.polkaKeys) {
+			return "", errors.New("invalid Polka API key")
+		}
+		return "", nil
+	}
+	return signature, err
+}
+
+// recordPolkaSignature returns errWebhookReplay if the signature was already
+// accepted. Signatures are kept in the database so that every instance
+// refuses a replay, including after a restart. Timestamps are accepted up
+// to one tolerance in the future, so signatures are kept for twice as long.
+func recordPolkaSignature(ctx context.Context, qtx *database.Queries, signature string) error {
+	if err := qtx.DeleteExpiredWebhookSignatures(ctx); err != nil {
+		return err
+	}
+	recorded, err := qtx.RecordWebhookSignature(ctx, database.RecordWebhookSignatureParams{
+		Signature: signature,
+		ExpiresAt: time.Now().UTC().Add(2 * polkaSignatureTolerance),
+	})
+	if err != nil {
+		return err
+	}
+	if recorded == 0 {
+		return errWebhookReplay
+	}
+	return nil
+}
+
+// Processing states of a logged webhook event.
+const (
+	webhookPending   = "pending"
+	webhookProcessed = "processed"
+	webhookIgnored   = "ignored"
+	webhookFailed    = "failed"
+)
+
+var (
+	errMalformedWebhook = errors.New("malformed webhook payload")
+	errUnknownUser      = errors.New("user could not be found")
+)
+
+type polkaPayload struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID string     `json:"user_id"`
+		Plan   string     `json:"plan"`
+		EndsAt *time.Time `json:"ends_at"`
+	} `json:"data"`
+}
+
+type WebhookEvent struct {
+	ID            string     `json:"id"`
+	ReceivedAt    time.Time  `json:"received_at"`
+	EventType     string     `json:"event_type"`
+	Payload       string     `json:"payload"`
+	Status        string     `json:"status"`
+	Attempts      int32      `json:"attempts"`
+	LastError     string     `json:"last_error"`
+	LastAttemptAt *time.Time `json:"last_attempt_at"`
+}
+
+func webhookEventFromDB(dbEvent database.WebhookEvent) WebhookEvent {
+	return WebhookEvent{
+		ID:            dbEvent.ID,
+		ReceivedAt:    dbEvent.ReceivedAt,
+		EventType:     dbEvent.EventType,
+		Payload:       dbEvent.Payload,
+		Status:        dbEvent.Status,
+		Attempts:      dbEvent.Attempts,
+		LastError:     dbEvent.LastError.String,
+		LastAttemptAt: nullTimePtr(dbEvent.LastAttemptAt),
+	}
+}
+
+// polkaEventID identifies a delivery. Only Polka's own event ID is used to
+// recognise retries. Deliveries without one get a fresh ID so they are still
+// logged, since two identical bodies can be two real events, such as a
+// renewal or an upgrade after a downgrade.
+func polkaEventID(body []byte) (string, string) {
+	var envelope polkaPayload
+	if err := json.Unmarshal(body, &envelope); err == nil && envelope.ID != "" {
+		return envelope.ID, envelope.Event
+	}
+	return "local:" + uuid.NewString(), envelope.Event
+}
+
+func (cfg *apiConfig) handlerUpgradeUser(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	signature, err := cfg.verifyPolkaRequest(r, body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	eventID, eventType := polkaEventID(body)
+	dbEvent, err := cfg.dbQueries.RecordWebhookEvent(r.Context(), database.RecordWebhookEventParams{
+		ID:        eventID,
+		EventType: eventType,
+		Payload:   string(body),
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if dbEvent.Status == webhookProcessed || dbEvent.Status == webhookIgnored {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// Only errors worth retrying get a 5xx, Polka gives up on 4xx.
+	err = cfg.processPolkaEvent(r.Context(), eventID, signature)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, errWebhookReplay):
+		w.WriteHeader(http.StatusUnauthorized)
+	case errors.Is(err, errMalformedWebhook), errors.Is(err, errUnknownPlan):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, errUnknownUser):
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// processPolkaEvent applies a logged webhook event. The event row stays
+// locked while it is applied, so concurrent retries of the same delivery
+// can't both go through. A signed delivery's signature is recorded in the
+// same transaction, so a delivery that fails to apply can still be retried
+// by Polka with the same signature.
+func (cfg *apiConfig) processPolkaEvent(ctx context.Context, eventID, signature string) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	dbEvent, err := qtx.LockWebhookEvent(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	if dbEvent.Status == webhookProcessed || dbEvent.Status == webhookIgnored {
+		return nil
+	}
+
+	if signature != "" {
+		err := recordPolkaSignature(ctx, qtx, signature)
+		if errors.Is(err, errWebhookReplay) {
+			// The replay stays in the log but can never be applied.
+			err := qtx.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{
+				ID:        eventID,
+				Status:    webhookIgnored,
+				LastError: sql.NullString{String: errWebhookReplay.Error(), Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			return errWebhookReplay
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	status, applyErr := applyPolkaPayload(ctx, qtx, []byte(dbEvent.Payload))
+	if applyErr != nil {
+		tx.Rollback()
+		err := cfg.dbQueries.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{
+			ID:        eventID,
+			Status:    webhookFailed,
+			LastError: sql.NullString{String: applyErr.Error(), Valid: true},
+		})
+		if err != nil {
+			log.Printf("Error recording failed webhook event %s: %v", eventID, err)
+		}
+		return applyErr
+	}
+
+	err = qtx.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{
+		ID:     eventID,
+		Status: status,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func applyPolkaPayload(ctx context.Context, qtx *database.Queries, body []byte) (string, error) {
+	var payload polkaPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", fmt.Errorf("%w: %v", errMalformedWebhook, err)
+	}
+
+	switch payload.Event {
+	case polkaUserUpgraded, polkaUserDowngraded, polkaUserCancelled, polkaUserRefunded:
+	default:
+		return webhookIgnored, nil
+	}
+
+	userID, err := uuid.Parse(payload.Data.UserID)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid user_id", errMalformedWebhook)
+	}
+	if _, err := qtx.GetUserByID(ctx, userID); errors.Is(err, sql.ErrNoRows) {
+		return "", errUnknownUser
+	} else if err != nil {
+		return "", err
+	}
+
+	err = applySubscriptionEvent(ctx, qtx, userID, payload.Event, payload.Data.Plan, payload.Data.EndsAt)
+	if err != nil {
+		return "", err
+	}
+	return webhookProcessed, nil
+}
+
+func (cfg *apiConfig) handlerListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := cfg.requireAdmin(r); err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	dbEvents, err := cfg.dbQueries.ListWebhookEvents(r.Context(), r.URL.Query().Get("status"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get webhook events")
+		return
+	}
+
+	events := make([]WebhookEvent, 0, len(dbEvents))
+	for _, dbEvent := range dbEvents {
+		events = append(events, webhookEventFromDB(dbEvent))
+	}
+	utils.RespondWithJSON(w, http.StatusOK, events)
+}
+
+func (cfg *apiConfig) handlerReplayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := cfg.requireAdmin(r); err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+
+	eventID := r.PathValue("eventID")
+	dbEvent, err := cfg.dbQueries.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Webhook event could not be found")
+		return
+	}
+	if dbEvent.Status == webhookProcessed || dbEvent.Status == webhookIgnored {
+		utils.RespondWithError(w, http.StatusConflict, "Webhook event has already been processed")
+		return
+	}
+
+	// The outcome, including any new error, is recorded on the event.
+	cfg.processPolkaEvent(r.Context(), eventID, "")
+
+	dbEvent, err = cfg.dbQueries.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get webhook event")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, webhookEventFromDB(dbEvent))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/auth"
+)
+
+// sendSignedPolkaRequest delivers body to the Polka webhook signed with key
+// at the given time and returns the response status.
+func sendSignedPolkaRequest(cfg *apiConfig, key string, timestamp int64, body string) int {
+	req := httptest.NewRequest(http.MethodPost, "/api/polka/webhooks", strings.NewReader(body))
+	req.Header.Set(auth.WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(auth.WebhookSignatureHeader, "v1="+auth.SignWebhook(key, timestamp, []byte(body)))
+	rec := httptest.NewRecorder()
+	cfg.handlerUpgradeUser(rec, req)
+	return rec.Code
+}
+
+func TestPolkaRetryAfterFailureIsNotAReplay(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.polkaKeys = []string{"polka-test-key"}
+	timestamp := time.Now().Unix()
+
+	// Nothing can be applied for an unknown user, so the signature must not
+	// be used up and the retry has to get the same answer.
+	body := `{"event":"user.upgraded","data":{"user_id":"` + uuid.NewString() + `"}}`
+	for range 2 {
+		if status := sendSignedPolkaRequest(cfg, "polka-test-key", timestamp, body); status != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", status)
+		}
+	}
+}
+
+func TestPolkaReplayIsRejected(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.polkaKeys = []string{"polka-test-key"}
+	dbUser, _ := createTestUser(t, cfg)
+	timestamp := time.Now().Unix()
+
+	body := `{"event":"user.upgraded","data":{"user_id":"` + dbUser.ID.String() + `"}}`
+	if status := sendSignedPolkaRequest(cfg, "polka-test-key", timestamp, body); status != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", status)
+	}
+	if status := sendSignedPolkaRequest(cfg, "polka-test-key", timestamp, body); status != http.StatusUnauthorized {
+		t.Errorf("expected 401 for the replay, got %d", status)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 50
+	minPollDuration     = 5 * time.Minute
+	maxPollDuration     = 7 * 24 * time.Hour
+	defaultPollDuration = 24 * time.Hour
+)
+
+type Poll struct {
+	ClosesAt   time.Time    `json:"closes_at"`
+	Closed     bool         `json:"closed"`
+	Options    []PollOption `json:"options"`
+	TotalVotes int64        `json:"total_votes"`
+	// ViewerVote is the option the viewer voted for, if they did.
+	ViewerVote *int32 `json:"viewer_vote"`
+}
+
+type PollOption struct {
+	Text  string `json:"text"`
+	Votes int64  `json:"votes"`
+}
+
+// pollParams is a poll attached to a chirp when it's created.
+type pollParams struct {
+	Options         []string `json:"options"`
+	DurationMinutes int      `json:"duration_minutes"`
+}
+
+// validatePoll checks the options of a poll and works out when it closes.
+// The poll opens when the chirp is published.
+func validatePoll(params *pollParams, opensAt time.Time) ([]string, time.Time, error) {
+	if len(params.Options) < minPollOptions || len(params.Options) > maxPollOptions {
+		return nil, time.Time{}, fmt.Errorf("a poll needs between %d and %d options", minPollOptions, maxPollOptions)
+	}
+	options := make([]string, 0, len(params.Options))
+	for i, option := range params.Options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			return nil, time.Time{}, fmt.Errorf("poll option %d is empty", i+1)
+		}
+		if len(option) > maxPollOptionLength {
+			return nil, time.Time{}, fmt.Errorf("poll option %d is too long, the limit is %d characters", i+1, maxPollOptionLength)
+		}
+		if slices.Contains(options, option) {
+			return nil, time.Time{}, fmt.Errorf("poll option %d is a duplicate", i+1)
+		}
+		options = append(options, option)
+	}
+
+	duration := defaultPollDuration
+	if params.DurationMinutes != 0 {
+		duration = time.Duration(params.DurationMinutes) * time.Minute
+	}
+	if duration < minPollDuration || duration > maxPollDuration {
+		return nil, time.Time{}, errors.New("polls must run for between 5 minutes and 7 days")
+	}
+	return options, opensAt.Add(duration), nil
+}
+
+// pollsByChirpID loads the polls of the given chirps with their tallies.
+// Chirps without a poll are left out.
+func (cfg *apiConfig) pollsByChirpID(ctx context.Context, chirpIDs []uuid.UUID) (map[uuid.UUID]*Poll, error) {
+	dbPolls, err := cfg.dbQueries.GetPollsByChirpIDs(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	polls := make(map[uuid.UUID]*Poll, len(dbPolls))
+	if len(dbPolls) == 0 {
+		return polls, nil
+	}
+
+	now := time.Now().UTC()
+	pollIDs := make([]uuid.UUID, 0, len(dbPolls))
+	for _, dbPoll := range dbPolls {
+		polls[dbPoll.ChirpID] = &Poll{
+			ClosesAt: dbPoll.ClosesAt,
+			Closed:   !dbPoll.ClosesAt.After(now),
+			Options:  []PollOption{},
+		}
+		pollIDs = append(pollIDs, dbPoll.ChirpID)
+	}
+
+	dbOptions, err := cfg.dbQueries.GetPollOptions(ctx, pollIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, dbOption := range dbOptions {
+		poll := polls[dbOption.ChirpID]
+		poll.Options = append(poll.Options, PollOption{
+			Text:  dbOption.Text,
+			Votes: dbOption.Votes,
+		})
+		poll.TotalVotes += dbOption.Votes
+	}
+	return polls, nil
+}
+
+// addViewerVotes fills in which option the viewer voted for in the polls of
+// the given chirps. Chirps are built without a viewer because they are also
+// broadcast to live feeds.
+func (cfg *apiConfig) addViewerVotes(ctx context.Context, chirps []Chirp, viewerID uuid.UUID) error {
+	if viewerID == uuid.Nil {
+		return nil
+	}
+	pollIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		if chirp.Poll != nil {
+			pollIDs = append(pollIDs, chirp.ID)
+		}
+	}
+	if len(pollIDs) == 0 {
+		return nil
+	}
+
+	dbVotes, err := cfg.dbQueries.GetPollVotes(ctx, database.GetPollVotesParams{
+		UserID:   viewerID,
+		ChirpIds: pollIDs,
+	})
+	if err != nil {
+		return err
+	}
+	for _, dbVote := range dbVotes {
+		for i := range chirps {
+			if chirps[i].ID == dbVote.ChirpID && chirps[i].Poll != nil {
+				position := dbVote.Position
+				chirps[i].Poll.ViewerVote = &position
+			}
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerVotePoll(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	var params struct {
+		Option *int32 `json:"option"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp could not be found")
+		return
+	}
+	blocked, err := cfg.isBlocked(r.Context(), userID, dbChirp.UserID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not record the vote")
+		return
+	}
+	if blocked || !chirpVisible(dbChirp, userID) || dbChirp.ScheduledFor.Valid {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp could not be found")
+		return
+	}
+
+	chirp, err := cfg.chirpFromDB(r.Context(), dbChirp)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not record the vote")
+		return
+	}
+	if chirp.Poll == nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp has no poll")
+		return
+	}
+	if chirp.Poll.Closed {
+		utils.RespondWithError(w, http.StatusConflict, "Poll is closed")
+		return
+	}
+	if params.Option == nil || *params.Option < 0 || int(*params.Option) >= len(chirp.Poll.Options) {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("option must be between 0 and %d", len(chirp.Poll.Options)-1))
+		return
+	}
+
+	voted, err := cfg.dbQueries.CastPollVote(r.Context(), database.CastPollVoteParams{
+		ChirpID:  chirpID,
+		UserID:   userID,
+		Position: *params.Option,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not record the vote")
+		return
+	}
+	if voted == 0 {
+		// The poll may also have closed since it was loaded.
+		dbPoll, err := cfg.dbQueries.GetPoll(r.Context(), chirpID)
+		if err == nil && !dbPoll.ClosesAt.After(time.Now().UTC()) {
+			utils.RespondWithError(w, http.StatusConflict, "Poll is closed")
+			return
+		}
+		utils.RespondWithError(w, http.StatusConflict, "User already voted in this poll")
+		return
+	}
+
+	chirp, err = cfg.chirpFromDB(r.Context(), dbChirp)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the Chirp")
+		return
+	}
+	chirps := []Chirp{chirp}
+	if err := cfg.addViewerVotes(r.Context(), chirps, userID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the Chirp")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, chirps[0])
+}
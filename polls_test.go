@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestValidatePoll(t *testing.T) {
+	opensAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		params  pollParams
+		wantErr bool
+	}{
+		{"default duration", pollParams{Options: []string{"yes", "no"}}, false},
+		{"one option", pollParams{Options: []string{"yes"}}, true},
+		{"too many options", pollParams{Options: []string{"a", "b", "c", "d", "e"}}, true},
+		{"empty option", pollParams{Options: []string{"yes", " "}}, true},
+		{"duplicate option", pollParams{Options: []string{"yes", " yes"}}, true},
+		{"too short", pollParams{Options: []string{"yes", "no"}, DurationMinutes: 4}, true},
+		{"too long", pollParams{Options: []string{"yes", "no"}, DurationMinutes: 7*24*60 + 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, closesAt, err := validatePoll(&tt.params, opensAt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && !closesAt.Equal(opensAt.Add(defaultPollDuration)) {
+				t.Errorf("expected the poll to close at %v, got %v", opensAt.Add(defaultPollDuration), closesAt)
+			}
+		})
+	}
+}
+
+// createTestPoll creates a chirp with a yes/no poll as the user behind token.
+func createTestPoll(t *testing.T, cfg *apiConfig, token string) Chirp {
+	t.Helper()
+	body := `{"body":"well?","poll":{"options":["yes","no"],"duration_minutes":60}}`
+	rec := serveTestRecorder(cfg.handlerCreateChirp, http.MethodPost, "/api/chirps", "/api/chirps", token, body)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	var chirp Chirp
+	if err := json.NewDecoder(rec.Body).Decode(&chirp); err != nil {
+		t.Fatal(err)
+	}
+	return chirp
+}
+
+func TestVoteTwiceConflicts(t *testing.T) {
+	cfg := newTestConfig(t)
+	_, authorToken := createTestUser(t, cfg)
+	_, token := createTestUser(t, cfg)
+	chirp := createTestPoll(t, cfg, authorToken)
+
+	path := "/api/chirps/" + chirp.ID.String() + "/vote"
+	rec := serveTestRecorder(cfg.handlerVotePoll, http.MethodPost, "/api/chirps/{chirpID}/vote", path, token, `{"option":1}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var voted Chirp
+	if err := json.NewDecoder(rec.Body).Decode(&voted); err != nil {
+		t.Fatal(err)
+	}
+	if voted.Poll.TotalVotes != 1 || voted.Poll.Options[1].Votes != 1 {
+		t.Errorf("expected one vote for option 1, got %+v", voted.Poll.Options)
+	}
+	if voted.Poll.ViewerVote == nil || *voted.Poll.ViewerVote != 1 {
+		t.Errorf("expected the viewer's vote to be option 1, got %v", voted.Poll.ViewerVote)
+	}
+
+	if status := serveTestRequest(cfg.handlerVotePoll, http.MethodPost, "/api/chirps/{chirpID}/vote", path, token, `{"option":0}`); status != http.StatusConflict {
+		t.Errorf("expected 409 when voting twice, got %d", status)
+	}
+	if status := serveTestRequest(cfg.handlerVotePoll, http.MethodPost, "/api/chirps/{chirpID}/vote", path, authorToken, `{"option":2}`); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown option, got %d", status)
+	}
+}
+
+func TestVoteAfterCloseConflicts(t *testing.T) {
+	cfg := newTestConfig(t)
+	_, authorToken := createTestUser(t, cfg)
+	_, token := createTestUser(t, cfg)
+	chirp := createTestPoll(t, cfg, authorToken)
+
+	if _, err := cfg.db.ExecContext(context.Background(), "UPDATE polls SET closes_at = NOW() - INTERVAL '1 minute' WHERE chirp_id = $1", chirp.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	path := "/api/chirps/" + chirp.ID.String() + "/vote"
+	if status := serveTestRequest(cfg.handlerVotePoll, http.MethodPost, "/api/chirps/{chirpID}/vote", path, token, `{"option":0}`); status != http.StatusConflict {
+		t.Errorf("expected 409 once the poll is closed, got %d", status)
+	}
+}
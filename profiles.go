@@ -0,0 +1,255 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/tsyrdev/chirpy/internal/auth"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+)
+
+var handlePattern = regexp.MustCompile(`^[a-z0-9_]{3,15}$`)
+
+// Handles that would be shadowed by other routes under /api/users.
+var reservedHandles = []string{"analytics", "avatar", "blocks", "export", "mutes", "profile", "subscription"}
+
+type Profile struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	Handle         string    `json:"handle"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	AvatarURL      string    `json:"avatar_url"`
+	IsRed          bool      `json:"is_chirpy_red"`
+	ChirpCount     int64     `json:"chirp_count"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func (cfg *apiConfig) handlerUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	// Fields left out of the request keep their current value.
+	var params struct {
+		Handle      *string `json:"handle"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+		AvatarURL   *string `json:"avatar_url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	dbUser, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User could not be found")
+		return
+	}
+
+	update := database.UpdateUserProfileParams{
+		ID:          userID,
+		Handle:      dbUser.Handle,
+		DisplayName: dbUser.DisplayName,
+		Bio:         dbUser.Bio,
+		AvatarUrl:   dbUser.AvatarUrl,
+	}
+	if params.Handle != nil {
+		handle := strings.ToLower(*params.Handle)
+		if !handlePattern.MatchString(handle) || slices.Contains(reservedHandles, handle) {
+			utils.RespondWithError(w, http.StatusBadRequest, "Handle must be 3-15 letters, digits or underscores")
+			return
+		}
+		update.Handle = sql.NullString{String: handle, Valid: true}
+	}
+	if params.DisplayName != nil {
+		if len(*params.DisplayName) > maxDisplayNameLength {
+			utils.RespondWithError(w, http.StatusBadRequest, "Display name is too long")
+			return
+		}
+		update.DisplayName = *params.DisplayName
+	}
+	if params.Bio != nil {
+		if len(*params.Bio) > maxBioLength {
+			utils.RespondWithError(w, http.StatusBadRequest, "Bio is too long")
+			return
+		}
+		update.Bio = *params.Bio
+	}
+	if params.AvatarURL != nil {
+		// Uploaded avatars are served by Chirpy itself under /api/media.
+		if *params.AvatarURL != "" && !strings.HasPrefix(*params.AvatarURL, "/api/media/") {
+			u, err := url.Parse(*params.AvatarURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				utils.RespondWithError(w, http.StatusBadRequest, "Avatar URL must be an http(s) URL")
+				return
+			}
+		}
+		update.AvatarUrl = *params.AvatarURL
+	}
+
+	dbUser, err = cfg.dbQueries.UpdateUserProfile(r.Context(), update)
+	if isUniqueViolation(err) {
+		utils.RespondWithError(w, http.StatusConflict, "Handle is already taken")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not update profile")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, userFromDB(dbUser))
+}
+
+func (cfg *apiConfig) handlerGetProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	viewerID, err := cfg.viewer(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	handle := strings.ToLower(r.PathValue("handle"))
+	dbUser, err := cfg.dbQueries.GetUserByHandle(r.Context(), sql.NullString{String: handle, Valid: true})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User could not be found")
+		return
+	}
+	// Banned users and users on either side of a block are hidden the same
+	// way as their chirps.
+	blocked, err := cfg.isBlocked(r.Context(), viewerID, dbUser.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the user")
+		return
+	}
+	if blocked || dbUser.BannedAt.Valid {
+		utils.RespondWithError(w, http.StatusNotFound, "User could not be found")
+		return
+	}
+
+	stats, err := cfg.dbQueries.GetUserStats(r.Context(), dbUser.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the user's stats")
+		return
+	}
+
+	profile := Profile{
+		ID:             dbUser.ID,
+		CreatedAt:      dbUser.CreatedAt,
+		Handle:         dbUser.Handle.String,
+		DisplayName:    dbUser.DisplayName,
+		Bio:            dbUser.Bio,
+		AvatarURL:      dbUser.AvatarUrl,
+		IsRed:          dbUser.IsChirpyRed,
+		ChirpCount:     stats.ChirpCount,
+		FollowerCount:  stats.FollowerCount,
+		FollowingCount: stats.FollowingCount,
+	}
+	utils.RespondWithJSON(w, http.StatusOK, profile)
+}
+
+func (cfg *apiConfig) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	handle := strings.ToLower(r.PathValue("handle"))
+	followee, err := cfg.dbQueries.GetUserByHandle(r.Context(), sql.NullString{String: handle, Valid: true})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User could not be found")
+		return
+	}
+	if followee.ID == userID {
+		utils.RespondWithError(w, http.StatusBadRequest, "Users can't follow themselves")
+		return
+	}
+	blocked, err := cfg.isBlocked(r.Context(), userID, followee.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not follow user")
+		return
+	}
+	if blocked {
+		utils.RespondWithError(w, http.StatusForbidden, "Can't follow a blocked user")
+		return
+	}
+
+	followed, err := cfg.dbQueries.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followee.ID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not follow user")
+		return
+	}
+	// Following a user again changes nothing and notifies no one.
+	if followed > 0 {
+		cfg.notify(r.Context(), followee.ID, userID, notificationFollow, uuid.NullUUID{})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	handle := strings.ToLower(r.PathValue("handle"))
+	followee, err := cfg.dbQueries.GetUserByHandle(r.Context(), sql.NullString{String: handle, Valid: true})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User could not be found")
+		return
+	}
+
+	err = cfg.dbQueries.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followee.ID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not unfollow user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
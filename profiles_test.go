@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+)
+
+func TestFollowUserNotifiesOnce(t *testing.T) {
+	cfg := newTestConfig(t)
+	_, token := createTestUser(t, cfg)
+	followee, _ := createTestUser(t, cfg)
+
+	path := "/api/users/" + followee.Handle.String + "/follow"
+	for range 2 {
+		status := serveTestRequest(cfg.handlerFollowUser, http.MethodPost, "/api/users/{handle}/follow", path, token, "")
+		if status != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d", status)
+		}
+	}
+
+	if n := countNotifications(t, cfg, followee.ID, notificationFollow); n != 1 {
+		t.Errorf("expected 1 follow notification, got %d", n)
+	}
+}
+
+func TestProfileHiddenFromBlockedUsers(t *testing.T) {
+	cfg := newTestConfig(t)
+	viewer, token := createTestUser(t, cfg)
+	other, _ := createTestUser(t, cfg)
+
+	path := "/api/users/" + other.Handle.String
+	if status := serveTestRequest(cfg.handlerGetProfile, http.MethodGet, "/api/users/{handle}", path, token, ""); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+
+	err := cfg.dbQueries.BlockUser(context.Background(), database.BlockUserParams{
+		BlockerID: other.ID,
+		BlockedID: viewer.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := serveTestRequest(cfg.handlerGetProfile, http.MethodGet, "/api/users/{handle}", path, token, ""); status != http.StatusNotFound {
+		t.Errorf("expected 404 for a user who blocked the viewer, got %d", status)
+	}
+}
+
+func TestProfileOfBannedUserIsNotFound(t *testing.T) {
+	cfg := newTestConfig(t)
+	banned, _ := createTestUser(t, cfg)
+
+	err := cfg.dbQueries.SetUserBan(context.Background(), database.SetUserBanParams{
+		ID:       banned.ID,
+		BannedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/api/users/" + banned.Handle.String
+	if status := serveTestRequest(cfg.handlerGetProfile, http.MethodGet, "/api/users/{handle}", path, "", ""); status != http.StatusNotFound {
+		t.Errorf("expected 404 for a banned user, got %d", status)
+	}
+}
+
+func TestNewUsersGetAHandle(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+
+	dbUser, err := cfg.dbQueries.CreateUser(ctx, database.CreateUserParams{
+		Email:          "t_" + uuid.NewString()[:8] + "@example.com",
+		HashedPassword: "unused",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cfg.dbQueries.DeleteUser(ctx, dbUser.ID)
+
+	if !handlePattern.MatchString(dbUser.Handle.String) {
+		t.Errorf("expected a generated handle, got %q", dbUser.Handle.String)
+	}
+}
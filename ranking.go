@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+)
+
+const (
+	// rankingWindow is how old a chirp can be and still be ranked by
+	// sort=top and sort=trending.
+	rankingWindow = 7 * 24 * time.Hour
+	// trendingGravity is how quickly the trending score falls with a
+	// chirp's age in hours. Higher values favour newer chirps.
+	trendingGravity = 1.5
+	// maxRankedChirps is how many chirps the ranked feeds return.
+	maxRankedChirps = 100
+)
+
+// scoreChirps recomputes the scores the ranked feeds are ordered by. Readers
+// keep seeing the previous scores until it commits.
+func (cfg *apiConfig) scoreChirps(ctx context.Context) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	err = qtx.ScoreChirps(ctx, database.ScoreChirpsParams{
+		Gravity: trendingGravity,
+		Since:   time.Now().UTC().Add(-rankingWindow),
+	})
+	if err != nil {
+		return err
+	}
+	if err := qtx.DeleteStaleChirpScores(ctx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// runChirpScoring periodically recomputes chirp scores. It is safe to run on
+// several instances at once.
+func (cfg *apiConfig) runChirpScoring(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := cfg.scoreChirps(context.Background()); err != nil {
+			log.Printf("Error scoring chirps: %v", err)
+		}
+	}
+}
+
+// rankedChirps returns the chirps for sort=top or sort=trending, optionally
+// by a single author.
+func (cfg *apiConfig) rankedChirps(ctx context.Context, sortParam string, authorID uuid.NullUUID) ([]database.Chirp, error) {
+	if sortParam == "top" {
+		return cfg.dbQueries.GetTopChirps(ctx, database.GetTopChirpsParams{
+			AuthorID:   authorID,
+			MaxResults: maxRankedChirps,
+		})
+	}
+	return cfg.dbQueries.GetTrendingChirps(ctx, database.GetTrendingChirpsParams{
+		AuthorID:   authorID,
+		MaxResults: maxRankedChirps,
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+)
+
+// likeTestChirp likes a chirp once for each of the given users.
+func likeTestChirp(t *testing.T, cfg *apiConfig, chirpID uuid.UUID, users []database.User) {
+	t.Helper()
+	for _, user := range users {
+		_, err := cfg.dbQueries.LikeChirp(context.Background(), database.LikeChirpParams{
+			UserID:  user.ID,
+			ChirpID: chirpID,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// ageTestChirp moves a chirp's creation time age into the past.
+func ageTestChirp(t *testing.T, cfg *apiConfig, chirpID uuid.UUID, age time.Duration) {
+	t.Helper()
+	if _, err := cfg.db.ExecContext(context.Background(), "UPDATE chirps SET created_at = NOW() - $2 * INTERVAL '1 second' WHERE id = $1", chirpID, age.Seconds()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRankedChirpsOrder(t *testing.T) {
+	cfg := newTestConfig(t)
+	author, _ := createTestUser(t, cfg)
+	var fans []database.User
+	for range 3 {
+		fan, _ := createTestUser(t, cfg)
+		fans = append(fans, fan)
+	}
+
+	// The older chirp has more likes, the newer one gets its single like
+	// sooner after being posted.
+	older := createTestChirp(t, cfg, author.ID, "older")
+	ageTestChirp(t, cfg, older.ID, 72*time.Hour)
+	likeTestChirp(t, cfg, older.ID, fans)
+	newer := createTestChirp(t, cfg, author.ID, "newer")
+	likeTestChirp(t, cfg, newer.ID, fans[:1])
+	// Likes by the author don't count.
+	quiet := createTestChirp(t, cfg, author.ID, "quiet")
+	likeTestChirp(t, cfg, quiet.ID, []database.User{author})
+	expired := createTestChirp(t, cfg, author.ID, "expired")
+	ageTestChirp(t, cfg, expired.ID, rankingWindow+time.Hour)
+	likeTestChirp(t, cfg, expired.ID, fans)
+
+	if err := cfg.scoreChirps(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	authorID := uuid.NullUUID{UUID: author.ID, Valid: true}
+	tests := []struct {
+		sort string
+		want []uuid.UUID
+	}{
+		{"top", []uuid.UUID{older.ID, newer.ID, quiet.ID}},
+		{"trending", []uuid.UUID{newer.ID, older.ID, quiet.ID}},
+	}
+	for _, tt := range tests {
+		dbChirps, err := cfg.rankedChirps(context.Background(), tt.sort, authorID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]uuid.UUID, 0, len(dbChirps))
+		for _, dbChirp := range dbChirps {
+			got = append(got, dbChirp.ID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.sort, tt.want, got)
+			continue
+		}
+		for i := range tt.want {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: expected %v, got %v", tt.sort, tt.want, got)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+const (
+	// maxScheduleAhead is how far in the future a chirp can be scheduled.
+	maxScheduleAhead = 365 * 24 * time.Hour
+	// scheduledChirpsBatch is how many due chirps are published per run.
+	scheduledChirpsBatch = 100
+)
+
+// publishScheduledChirps makes due scheduled chirps live and announces them
+// like newly created chirps.
+func (cfg *apiConfig) publishScheduledChirps(ctx context.Context) error {
+	for {
+		dbChirps, err := cfg.dbQueries.PublishDueChirps(ctx, scheduledChirpsBatch)
+		if err != nil {
+			return err
+		}
+
+		if _, err := cfg.announceChirps(ctx, dbChirps); err != nil {
+			return err
+		}
+
+		if len(dbChirps) < scheduledChirpsBatch {
+			return nil
+		}
+	}
+}
+
+// runScheduledChirps periodically publishes scheduled chirps that are due.
+// It is safe to run on several instances at once.
+func (cfg *apiConfig) runScheduledChirps(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := cfg.publishScheduledChirps(context.Background()); err != nil {
+			log.Printf("Error publishing scheduled chirps: %v", err)
+		}
+	}
+}
+
+func (cfg *apiConfig) handlerGetScheduledChirps(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsRead)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbChirps, err := cfg.dbQueries.GetScheduledChirps(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get scheduled chirps")
+		return
+	}
+	chirps, err := cfg.chirpsFromDB(r.Context(), dbChirps)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get scheduled chirps")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, chirps)
+}
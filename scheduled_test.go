@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+)
+
+// createScheduledTestChirp creates a chirp scheduled for publishAt.
+func createScheduledTestChirp(t *testing.T, cfg *apiConfig, userID uuid.UUID, publishAt time.Time) database.Chirp {
+	t.Helper()
+	dbChirp, err := cfg.dbQueries.CreateChirps(context.Background(), database.CreateChirpsParams{
+		Body:         "later",
+		UserID:       userID,
+		ScheduledFor: sql.NullTime{Time: publishAt, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dbChirp
+}
+
+func TestScheduledChirpIsOnlyVisibleToItsAuthor(t *testing.T) {
+	cfg := newTestConfig(t)
+	author, authorToken := createTestUser(t, cfg)
+	_, otherToken := createTestUser(t, cfg)
+	dbChirp := createScheduledTestChirp(t, cfg, author.ID, time.Now().Add(time.Hour))
+
+	path := "/api/chirps/" + dbChirp.ID.String()
+	if status := serveTestRequest(cfg.handlerGetChirp, http.MethodGet, "/api/chirps/{chirpID}", path, otherToken, ""); status != http.StatusNotFound {
+		t.Errorf("expected 404 for other users, got %d", status)
+	}
+	if status := serveTestRequest(cfg.handlerGetChirp, http.MethodGet, "/api/chirps/{chirpID}", path, authorToken, ""); status != http.StatusOK {
+		t.Errorf("expected 200 for the author, got %d", status)
+	}
+
+	// A chirp that isn't due yet is left alone.
+	if err := cfg.publishScheduledChirps(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	dbChirp, err := cfg.dbQueries.GetChirp(context.Background(), dbChirp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dbChirp.ScheduledFor.Valid {
+		t.Error("expected the chirp to still be scheduled")
+	}
+}
+
+func TestDueScheduledChirpIsPublished(t *testing.T) {
+	cfg := newTestConfig(t)
+	author, _ := createTestUser(t, cfg)
+	_, otherToken := createTestUser(t, cfg)
+	dbChirp := createScheduledTestChirp(t, cfg, author.ID, time.Now().Add(-time.Minute))
+
+	if err := cfg.publishScheduledChirps(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirp(context.Background(), dbChirp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbChirp.ScheduledFor.Valid {
+		t.Error("expected the chirp to be published")
+	}
+	path := "/api/chirps/" + dbChirp.ID.String()
+	if status := serveTestRequest(cfg.handlerGetChirp, http.MethodGet, "/api/chirps/{chirpID}", path, otherToken, ""); status != http.StatusOK {
+		t.Errorf("expected 200 once published, got %d", status)
+	}
+}
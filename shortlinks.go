@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/internal/linkpreview"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+const (
+	// shortURLLength is how many characters a URL counts for towards the
+	// chirp length limit, however long it really is.
+	shortURLLength  = 23
+	shortCodeLength = 8
+	// maxShortCodeAttempts is how many random codes are tried before giving
+	// up on a link.
+	maxShortCodeAttempts = 5
+)
+
+const shortCodeAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+type ShortLink struct {
+	Code      string    `json:"code"`
+	ShortURL  string    `json:"short_url"`
+	URL       string    `json:"url"`
+	Clicks    int64     `json:"clicks"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// chirpLength is the length of a chirp's body as counted against the
+// user's limit. URLs count as shortURLLength characters since they are
+// shortened, unless they are too long to be linked at all.
+func chirpLength(body string) int {
+	length := len(body)
+	for _, loc := range linkpreview.FindURLs(body) {
+		if n := loc[1] - loc[0]; n <= linkpreview.MaxURLLength {
+			length += shortURLLength - n
+		}
+	}
+	return length
+}
+
+func newShortCode() (string, error) {
+	b := make([]byte, shortCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = shortCodeAlphabet[int(b[i])%len(shortCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// shortLinkURL is the address a short link is served at.
+func (cfg *apiConfig) shortLinkURL(code string) string {
+	return cfg.baseURL + "/l/" + code
+}
+
+// parseBaseURL checks BASE_URL, the server's public address. Short links
+// are written into chirps with it, so it has to be absolute.
+func parseBaseURL(value string) (string, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("BASE_URL must be an absolute http or https URL")
+	}
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+// createShortLink stores a new short link to target for the chirp and
+// returns its code.
+func createShortLink(ctx context.Context, qtx *database.Queries, dbChirp database.Chirp, target string) (string, error) {
+	for range maxShortCodeAttempts {
+		code, err := newShortCode()
+		if err != nil {
+			return "", err
+		}
+		_, err = qtx.CreateShortLink(ctx, database.CreateShortLinkParams{
+			Code:    code,
+			ChirpID: dbChirp.ID,
+			UserID:  dbChirp.UserID,
+			Url:     target,
+		})
+		// No rows means the code is taken.
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return code, nil
+	}
+	return "", errors.New("could not find a free short link code")
+}
+
+// storeLinks handles the links in a new or edited chirp. Their previews
+// are queued, and each URL is rewritten to a short link that counts
+// clicks. Short links the chirp already has are kept so that edits don't
+// reset their clicks. It returns the chirp with its rewritten body.
+func (cfg *apiConfig) storeLinks(ctx context.Context, qtx *database.Queries, dbChirp database.Chirp) (database.Chirp, error) {
+	dbLinks, err := qtx.GetChirpShortLinks(ctx, dbChirp.ID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	codes := make(map[string]string, len(dbLinks))
+	body := dbChirp.Body
+	for _, dbLink := range dbLinks {
+		codes[dbLink.Url] = dbLink.Code
+		body = strings.ReplaceAll(body, cfg.shortLinkURL(dbLink.Code), dbLink.Url)
+	}
+
+	if err := attachLinks(ctx, qtx, dbChirp.ID, body); err != nil {
+		return database.Chirp{}, err
+	}
+
+	var shortened strings.Builder
+	last := 0
+	for _, loc := range linkpreview.FindURLs(body) {
+		target := body[loc[0]:loc[1]]
+		shortened.WriteString(body[last:loc[0]])
+		last = loc[1]
+
+		// Links to other chirps' short links and URLs too long to store
+		// are left as they are.
+		if strings.HasPrefix(target, cfg.shortLinkURL("")) || len(target) > linkpreview.MaxURLLength {
+			shortened.WriteString(target)
+			continue
+		}
+		code, ok := codes[target]
+		if !ok {
+			code, err = createShortLink(ctx, qtx, dbChirp, target)
+			if err != nil {
+				return database.Chirp{}, err
+			}
+			codes[target] = code
+		}
+		shortened.WriteString(cfg.shortLinkURL(code))
+	}
+	shortened.WriteString(body[last:])
+
+	if shortened.String() == dbChirp.Body {
+		return dbChirp, nil
+	}
+	return qtx.UpdateChirpBody(ctx, database.UpdateChirpBodyParams{
+		ID:   dbChirp.ID,
+		Body: shortened.String(),
+	})
+}
+
+func (cfg *apiConfig) handlerFollowShortLink(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := cfg.viewer(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	// Links only work while their chirp can be seen.
+	dbLink, err := cfg.dbQueries.GetShortLink(r.Context(), r.PathValue("code"))
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "Link could not be found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not follow the link")
+		return
+	}
+	dbChirp, err := cfg.dbQueries.GetChirp(r.Context(), dbLink.ChirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "Link could not be found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not follow the link")
+		return
+	}
+	visible, err := cfg.canViewChirp(r.Context(), dbChirp, viewerID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not follow the link")
+		return
+	}
+	if !visible {
+		utils.RespondWithError(w, http.StatusNotFound, "Link could not be found")
+		return
+	}
+
+	target, err := cfg.dbQueries.FollowShortLink(r.Context(), dbLink.Code)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.RespondWithError(w, http.StatusNotFound, "Link could not be found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not follow the link")
+		return
+	}
+	// Not a permanent redirect, so browsers come back and every click is
+	// counted.
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (cfg *apiConfig) handlerGetChirpLinks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	userID, err := cfg.authenticate(r, scopeChirpsRead)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Chirp could not be found")
+		return
+	}
+	if userID != dbChirp.UserID {
+		utils.RespondWithError(w, http.StatusForbidden, "Chirp does not belong to user")
+		return
+	}
+
+	dbLinks, err := cfg.dbQueries.GetChirpShortLinks(r.Context(), chirpID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the chirp's links")
+		return
+	}
+	links := make([]ShortLink, 0, len(dbLinks))
+	for _, dbLink := range dbLinks {
+		links = append(links, ShortLink{
+			Code:      dbLink.Code,
+			ShortURL:  cfg.shortLinkURL(dbLink.Code),
+			URL:       dbLink.Url,
+			Clicks:    dbLink.Clicks,
+			CreatedAt: dbLink.CreatedAt,
+		})
+	}
+	utils.RespondWithJSON(w, http.StatusOK, links)
+}
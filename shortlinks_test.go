@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestParseBaseURL(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "https://chirpy.example/", want: "https://chirpy.example"},
+		{value: "http://localhost:8080", want: "http://localhost:8080"},
+		{value: "", wantErr: true},
+		{value: "/l", wantErr: true},
+		{value: "chirpy.example", wantErr: true},
+		{value: "ftp://chirpy.example", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseBaseURL(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseBaseURL(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseBaseURL(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestShortLinkOfHiddenChirpIsNotFollowed(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+	author, _ := createTestUser(t, cfg)
+	dbChirp := createTestChirp(t, cfg, author.ID, "see https://example.com")
+
+	code, err := createShortLink(ctx, cfg.dbQueries, dbChirp, "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/l/" + code
+	if status := serveTestRequest(cfg.handlerFollowShortLink, http.MethodGet, "/l/{code}", path, "", ""); status != http.StatusFound {
+		t.Fatalf("expected 302, got %d", status)
+	}
+
+	if _, err := cfg.dbQueries.HideChirp(ctx, dbChirp.ID); err != nil {
+		t.Fatal(err)
+	}
+	if status := serveTestRequest(cfg.handlerFollowShortLink, http.MethodGet, "/l/{code}", path, "", ""); status != http.StatusNotFound {
+		t.Errorf("expected 404 for a hidden chirp's link, got %d", status)
+	}
+}
@@ -0,0 +1,37 @@
+-- name: RecordChirpViews :exec
+-- Views of chirps deleted since they were counted are dropped.
+INSERT INTO chirp_views (chirp_id, hour, views)
+SELECT counted.chirp_id, date_trunc('hour', NOW()), counted.views
+FROM unnest(sqlc.arg(chirp_ids)::UUID[], sqlc.arg(views)::BIGINT[]) AS counted(chirp_id, views)
+WHERE counted.chirp_id IN (SELECT id FROM chirps)
+ON CONFLICT (chirp_id, hour) DO UPDATE SET views = chirp_views.views + EXCLUDED.views;
+
+-- name: GetUserAnalytics :many
+-- Totals per metric and bucket since the given time. Buckets with nothing in
+-- them are left out. The author's own likes and replies aren't counted.
+SELECT 'views'::TEXT AS metric, date_trunc(sqlc.arg(bucket)::TEXT, chirp_views.hour)::TIMESTAMP AS bucket_start, SUM(chirp_views.views)::BIGINT AS total
+FROM chirp_views
+JOIN chirps ON chirps.id = chirp_views.chirp_id
+WHERE chirps.user_id = sqlc.arg(user_id) AND chirp_views.hour >= sqlc.arg(since)::TIMESTAMP
+GROUP BY 2
+UNION ALL
+SELECT 'likes', date_trunc(sqlc.arg(bucket)::TEXT, likes.created_at)::TIMESTAMP, COUNT(*)
+FROM likes
+JOIN chirps ON chirps.id = likes.chirp_id
+WHERE chirps.user_id = sqlc.arg(user_id) AND likes.user_id <> sqlc.arg(user_id)
+AND likes.created_at >= sqlc.arg(since)::TIMESTAMP
+GROUP BY 2
+UNION ALL
+SELECT 'replies', date_trunc(sqlc.arg(bucket)::TEXT, replies.created_at)::TIMESTAMP, COUNT(*)
+FROM chirps AS replies
+JOIN chirps ON chirps.id = replies.in_reply_to
+WHERE chirps.user_id = sqlc.arg(user_id) AND replies.user_id <> sqlc.arg(user_id)
+AND replies.scheduled_for IS NULL AND replies.deleted_at IS NULL
+AND replies.created_at >= sqlc.arg(since)::TIMESTAMP
+GROUP BY 2
+UNION ALL
+SELECT 'new_followers', date_trunc(sqlc.arg(bucket)::TEXT, follows.created_at)::TIMESTAMP, COUNT(*)
+FROM follows
+WHERE follows.followee_id = sqlc.arg(user_id) AND follows.created_at >= sqlc.arg(since)::TIMESTAMP
+GROUP BY 2
+ORDER BY bucket_start, metric;
@@ -0,0 +1,35 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (id, created_at, updated_at, user_id, name, key_prefix, key_hash, scopes, expires_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: GetAPIKeyByHash :one
+SELECT *
+FROM api_keys
+WHERE key_hash = $1;
+
+-- name: GetUserAPIKeys :many
+SELECT *
+FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: RevokeAPIKey :execrows
+UPDATE api_keys
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = NOW()
+WHERE id = $1;
@@ -0,0 +1,35 @@
+-- name: HasBlockBetween :one
+SELECT EXISTS (
+    SELECT 1
+    FROM blocks
+    WHERE (blocker_id = sqlc.arg(user_id) AND blocked_id = ANY(sqlc.arg(other_ids)::UUID[]))
+    OR (blocked_id = sqlc.arg(user_id) AND blocker_id = ANY(sqlc.arg(other_ids)::UUID[]))
+);
+
+-- name: BlockUser :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :exec
+DELETE FROM blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: GetBlocks :many
+SELECT *
+FROM blocks
+WHERE blocker_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetHiddenUserIDs :many
+-- Users whose chirps are hidden from a viewer: anyone blocked either way
+-- and anyone the viewer muted.
+SELECT blocked_id AS user_id FROM blocks WHERE blocker_id = $1
+UNION
+SELECT blocker_id FROM blocks WHERE blocked_id = $1
+UNION
+SELECT muted_id FROM mutes WHERE muter_id = $1;
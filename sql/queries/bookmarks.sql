@@ -0,0 +1,33 @@
+-- name: BookmarkChirp :exec
+INSERT INTO bookmarks (user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveBookmark :exec
+DELETE FROM bookmarks
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetBookmarks :many
+-- Bookmarks of deleted chirps and of chirps by banned users are left out.
+SELECT bookmarks.*
+FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = sqlc.arg(user_id)
+AND chirps.deleted_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.banned_at IS NOT NULL
+)
+AND (sqlc.narg(before)::TIMESTAMP IS NULL OR bookmarks.created_at < sqlc.narg(before)::TIMESTAMP)
+ORDER BY bookmarks.created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: GetUserBookmarks :many
+-- Every bookmark the user has, for exporting their data.
+SELECT *
+FROM bookmarks
+WHERE user_id = $1
+ORDER BY created_at DESC;
@@ -0,0 +1,56 @@
+-- name: ScoreChirps :exec
+-- Scores published chirps created since the given time. Engagement counts
+-- likes and replies from other users, with replies worth two likes and
+-- twenty views worth one. The trending score divides it by the chirp's age,
+-- so newer chirps need less engagement to rank highly.
+INSERT INTO chirp_scores (chirp_id, computed_at, engagement, trending_score)
+SELECT chirps.id, NOW(), scored.engagement, scored.engagement / power((EXTRACT(EPOCH FROM NOW() - chirps.created_at) / 3600 + 2)::DOUBLE PRECISION, sqlc.arg(gravity)::DOUBLE PRECISION)
+FROM chirps
+CROSS JOIN LATERAL (
+    SELECT (
+        (SELECT COUNT(*) FROM likes WHERE likes.chirp_id = chirps.id AND likes.user_id <> chirps.user_id)
+        + 2 * (
+            SELECT COUNT(*) FROM chirps AS replies
+            WHERE replies.in_reply_to = chirps.id AND replies.user_id <> chirps.user_id
+            AND replies.scheduled_for IS NULL AND replies.deleted_at IS NULL
+        )
+        + (SELECT COALESCE(SUM(chirp_views.views), 0) FROM chirp_views WHERE chirp_views.chirp_id = chirps.id) / 20.0
+    )::DOUBLE PRECISION AS engagement
+) AS scored
+WHERE chirps.created_at >= sqlc.arg(since)::TIMESTAMP
+AND chirps.scheduled_for IS NULL AND chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+ON CONFLICT (chirp_id) DO UPDATE
+SET computed_at = EXCLUDED.computed_at, engagement = EXCLUDED.engagement, trending_score = EXCLUDED.trending_score;
+
+-- name: DeleteStaleChirpScores :exec
+-- Run in the same transaction as ScoreChirps to remove the chirps it didn't
+-- score, such as chirps that got too old or were deleted or hidden since.
+DELETE FROM chirp_scores
+WHERE computed_at < NOW();
+
+-- name: GetTopChirps :many
+-- Scored chirps with the most engagement first, optionally by one author.
+SELECT chirps.*
+FROM chirp_scores
+JOIN chirps ON chirps.id = chirp_scores.chirp_id
+WHERE chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.banned_at IS NOT NULL
+)
+AND (sqlc.narg(author_id)::UUID IS NULL OR chirps.user_id = sqlc.narg(author_id)::UUID)
+ORDER BY chirp_scores.engagement DESC, chirps.created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: GetTrendingChirps :many
+-- Scored chirps with the highest trending score first, optionally by one
+-- author.
+SELECT chirps.*
+FROM chirp_scores
+JOIN chirps ON chirps.id = chirp_scores.chirp_id
+WHERE chirps.deleted_at IS NULL AND chirps.hidden_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.banned_at IS NOT NULL
+)
+AND (sqlc.narg(author_id)::UUID IS NULL OR chirps.user_id = sqlc.narg(author_id)::UUID)
+ORDER BY chirp_scores.trending_score DESC, chirps.created_at DESC
+LIMIT sqlc.arg(max_results);
@@ -1,28 +1,110 @@
 -- name: CreateChirps :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, scheduled_for)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4
 )
 RETURNING *; 
 
 -- name: GetAllChirps :many
+-- Scheduled chirps and chirps by banned users are left out.
 SELECT *
-FROM chirps; 
+FROM chirps
+WHERE scheduled_for IS NULL AND deleted_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.banned_at IS NOT NULL
+);
 
 -- name: GetAuthorChirps :many
 SELECT * 
 FROM chirps 
-WHERE user_id = $1; 
+WHERE user_id = $1
+AND scheduled_for IS NULL AND deleted_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.banned_at IS NOT NULL
+);
 
 -- name: GetChirp :one
 SELECT *
 FROM chirps 
-WHERE id = $1;
+WHERE id = $1 AND deleted_at IS NULL;
 
 -- name: DeleteChirp :exec
+-- Chirps are only marked as deleted. PurgeDeletedChirps removes them later.
+UPDATE chirps
+SET deleted_at = NOW(), deleted_by = $2
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: GetChirpsByIDs :many
+SELECT *
+FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::UUID[]) AND deleted_at IS NULL;
+
+-- name: HideChirp :execrows
+UPDATE chirps
+SET hidden_at = NOW()
+WHERE id = $1 AND hidden_at IS NULL;
+
+-- name: UnhideChirp :execrows
+UPDATE chirps
+SET hidden_at = NULL
+WHERE id = $1 AND hidden_at IS NOT NULL;
+
+-- name: GetScheduledChirps :many
+SELECT *
+FROM chirps
+WHERE user_id = $1 AND scheduled_for IS NOT NULL AND deleted_at IS NULL
+ORDER BY scheduled_for;
+
+-- name: PublishDueChirps :many
+-- Several instances may run the publisher at once. Rows locked by another
+-- instance are skipped, so each chirp is published exactly once.
+-- Chirps by banned authors stay scheduled until they are unbanned.
+UPDATE chirps
+SET created_at = NOW(), updated_at = NOW(), scheduled_for = NULL
+WHERE id IN (
+    SELECT id
+    FROM chirps
+    WHERE scheduled_for <= NOW() AND deleted_at IS NULL
+    AND NOT EXISTS (
+        SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.banned_at IS NOT NULL
+    )
+    ORDER BY scheduled_for
+    LIMIT sqlc.arg(max_results)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: GetDeletedChirps :many
+-- Chirps the user deleted themselves that can still be restored.
+SELECT *
+FROM chirps
+WHERE user_id = sqlc.arg(user_id) AND deleted_by = sqlc.arg(user_id)
+AND deleted_at > sqlc.arg(deleted_after)::TIMESTAMP
+ORDER BY deleted_at DESC;
+
+-- name: RestoreChirp :one
+-- Only chirps the owner deleted themselves can be restored.
+UPDATE chirps
+SET deleted_at = NULL, deleted_by = NULL
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id) AND deleted_by = sqlc.arg(user_id)
+AND deleted_at > sqlc.arg(deleted_after)::TIMESTAMP
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
+-- Chirps removed by moderators are kept longer than those deleted by
+-- their owner.
 DELETE FROM chirps
-WHERE id = $1;
+WHERE (deleted_by = user_id AND deleted_at < sqlc.arg(owner_deleted_before)::TIMESTAMP)
+OR (deleted_by IS DISTINCT FROM user_id AND deleted_at < sqlc.arg(moderator_deleted_before)::TIMESTAMP);
@@ -0,0 +1,109 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, created_by)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1
+)
+RETURNING *;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+);
+
+-- name: FindDirectConversation :one
+SELECT conversation_id
+FROM conversation_members
+WHERE conversation_id IN (
+    SELECT conversation_id
+    FROM conversation_members
+    WHERE user_id = sqlc.arg(user_id)
+)
+GROUP BY conversation_id
+HAVING COUNT(*) = 2 AND bool_or(user_id = sqlc.arg(other_id))
+LIMIT 1;
+
+-- name: GetConversation :one
+SELECT *
+FROM conversations
+WHERE id = $1;
+
+-- name: GetUserConversations :many
+SELECT conversations.*
+FROM conversations
+JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+WHERE conversation_members.user_id = sqlc.arg(user_id)
+AND (sqlc.narg(before)::TIMESTAMP IS NULL OR conversations.updated_at < sqlc.narg(before)::TIMESTAMP)
+ORDER BY conversations.updated_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: GetConversationMembers :many
+SELECT *
+FROM conversation_members
+WHERE conversation_id = ANY(sqlc.arg(conversation_ids)::UUID[])
+ORDER BY joined_at;
+
+-- name: IsConversationMember :one
+SELECT EXISTS (
+    SELECT 1
+    FROM conversation_members
+    WHERE conversation_id = $1 AND user_id = $2
+);
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, conversation_id, sender_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetMessages :many
+SELECT *
+FROM messages
+WHERE conversation_id = sqlc.arg(conversation_id)
+AND (sqlc.narg(before)::TIMESTAMP IS NULL OR created_at < sqlc.narg(before)::TIMESTAMP)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: GetLastMessages :many
+SELECT DISTINCT ON (conversation_id) *
+FROM messages
+WHERE conversation_id = ANY(sqlc.arg(conversation_ids)::UUID[])
+ORDER BY conversation_id, created_at DESC;
+
+-- name: GetUnreadMessageCounts :many
+SELECT messages.conversation_id, COUNT(*) AS unread_count
+FROM messages
+JOIN conversation_members ON conversation_members.conversation_id = messages.conversation_id
+    AND conversation_members.user_id = sqlc.arg(user_id)
+WHERE messages.conversation_id = ANY(sqlc.arg(conversation_ids)::UUID[])
+AND messages.sender_id <> sqlc.arg(user_id)
+AND (conversation_members.last_read_at IS NULL OR messages.created_at > conversation_members.last_read_at)
+GROUP BY messages.conversation_id;
+
+-- name: GetUserMessages :many
+-- Every message in the user's conversations, for exporting their data.
+SELECT messages.*
+FROM messages
+JOIN conversation_members ON conversation_members.conversation_id = messages.conversation_id
+WHERE conversation_members.user_id = $1
+ORDER BY messages.conversation_id, messages.created_at;
@@ -0,0 +1,18 @@
+-- name: FollowUser :execrows
+-- Returns 0 rows if the user was already followed.
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: GetFolloweeIDs :many
+SELECT followee_id
+FROM follows
+WHERE follower_id = $1;
@@ -0,0 +1,12 @@
+-- name: LikeChirp :execrows
+INSERT INTO likes (user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikeChirp :execrows
+DELETE FROM likes
+WHERE user_id = $1 AND chirp_id = $2;
@@ -0,0 +1,52 @@
+-- name: EnqueueLinkPreviews :exec
+-- URLs that already have a preview, or are waiting for one, are skipped.
+INSERT INTO link_previews (url, created_at, updated_at, status, next_attempt_at)
+SELECT urls.url, NOW(), NOW(), 'pending', NOW()
+FROM unnest(sqlc.arg(urls)::TEXT[]) AS urls(url)
+ON CONFLICT (url) DO NOTHING;
+
+-- name: AddChirpLinks :exec
+INSERT INTO chirp_links (chirp_id, position, url)
+SELECT sqlc.arg(chirp_id), (links.ord - 1)::INTEGER, links.url
+FROM unnest(sqlc.arg(urls)::TEXT[]) WITH ORDINALITY AS links(url, ord);
+
+-- name: DeleteChirpLinks :exec
+DELETE FROM chirp_links
+WHERE chirp_id = $1;
+
+-- name: ClaimLinkPreviews :many
+-- Claimed previews are leased for five minutes so other workers skip them
+-- while they are being fetched.
+UPDATE link_previews
+SET next_attempt_at = NOW() + INTERVAL '5 minutes', updated_at = NOW()
+WHERE url IN (
+    SELECT url
+    FROM link_previews
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: FinishLinkPreview :exec
+UPDATE link_previews
+SET status = $2,
+    attempts = attempts + 1,
+    next_attempt_at = $3,
+    title = $4,
+    description = $5,
+    image_url = $6,
+    fetched_at = CASE WHEN $2 = 'ready' THEN NOW() ELSE fetched_at END,
+    updated_at = NOW()
+WHERE url = $1;
+
+-- name: GetChirpLinkPreviews :many
+-- Previews that are ready for the given chirps, in the order the links
+-- appear in each chirp.
+SELECT chirp_links.chirp_id, link_previews.url, link_previews.title, link_previews.description, link_previews.image_url
+FROM chirp_links
+JOIN link_previews ON link_previews.url = chirp_links.url
+WHERE chirp_links.chirp_id = ANY(sqlc.arg(chirp_ids)::UUID[])
+AND link_previews.status = 'ready'
+ORDER BY chirp_links.chirp_id, chirp_links.position;
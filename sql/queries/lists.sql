@@ -0,0 +1,73 @@
+-- name: CreateList :one
+INSERT INTO lists (id, created_at, updated_at, owner_id, name, description, visibility)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetList :one
+SELECT *
+FROM lists
+WHERE id = $1;
+
+-- name: GetListsByOwner :many
+-- Private lists are only included for their owner.
+SELECT *
+FROM lists
+WHERE owner_id = sqlc.arg(owner_id)
+AND (sqlc.arg(include_private)::BOOLEAN OR visibility = 'public')
+ORDER BY created_at;
+
+-- name: UpdateList :one
+UPDATE lists
+SET name = $3, description = $4, visibility = $5, updated_at = NOW()
+WHERE id = $1 AND owner_id = $2
+RETURNING *;
+
+-- name: DeleteList :execrows
+DELETE FROM lists
+WHERE id = $1 AND owner_id = $2;
+
+-- name: AddListMember :exec
+INSERT INTO list_members (list_id, user_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveListMember :exec
+DELETE FROM list_members
+WHERE list_id = $1 AND user_id = $2;
+
+-- name: GetListMembers :many
+SELECT user_id
+FROM list_members
+WHERE list_id = $1
+ORDER BY created_at;
+
+-- name: CountListMembers :one
+SELECT COUNT(*)
+FROM list_members
+WHERE list_id = $1;
+
+-- name: GetListChirps :many
+-- The timeline of a list: published chirps by its members, newest first.
+SELECT chirps.*
+FROM chirps
+JOIN list_members ON list_members.user_id = chirps.user_id
+WHERE list_members.list_id = sqlc.arg(list_id)
+AND chirps.scheduled_for IS NULL AND chirps.deleted_at IS NULL
+AND NOT EXISTS (
+    SELECT 1 FROM users WHERE users.id = chirps.user_id AND users.banned_at IS NOT NULL
+)
+AND (sqlc.narg(before)::TIMESTAMP IS NULL OR chirps.created_at < sqlc.narg(before)::TIMESTAMP)
+ORDER BY chirps.created_at DESC
+LIMIT sqlc.arg(max_results);
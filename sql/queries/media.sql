@@ -0,0 +1,55 @@
+-- name: CreateMediaFile :one
+INSERT INTO media_files (id, created_at, user_id, content_type, size_bytes, width, height, storage_key, thumbnail_key)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+RETURNING *;
+
+-- name: GetMediaFile :one
+SELECT *
+FROM media_files
+WHERE id = $1;
+
+-- name: AttachMediaFile :execrows
+UPDATE media_files
+SET chirp_id = $1, position = $2
+WHERE id = $3 AND user_id = $4 AND chirp_id IS NULL;
+
+-- name: GetChirpMediaFiles :many
+SELECT *
+FROM media_files
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::UUID[])
+ORDER BY chirp_id, position;
+
+-- name: GetUserMediaFiles :many
+SELECT *
+FROM media_files
+WHERE user_id = $1;
+
+-- name: GetPurgeableMediaFiles :many
+-- The media of the chirps PurgeDeletedChirps removes with the same
+-- arguments.
+SELECT media_files.*
+FROM media_files
+JOIN chirps ON chirps.id = media_files.chirp_id
+WHERE (chirps.deleted_by = chirps.user_id AND chirps.deleted_at < sqlc.arg(owner_deleted_before)::TIMESTAMP)
+OR (chirps.deleted_by IS DISTINCT FROM chirps.user_id AND chirps.deleted_at < sqlc.arg(moderator_deleted_before)::TIMESTAMP);
+
+-- name: DeleteUnattachedMediaFiles :many
+-- Uploads that never made it into a chirp and aren't anyone's avatar.
+DELETE FROM media_files
+WHERE chirp_id IS NULL
+AND created_at < sqlc.arg(created_before)::TIMESTAMP
+AND NOT EXISTS (
+    SELECT 1 FROM users WHERE users.avatar_url = '/api/media/' || media_files.id::TEXT
+)
+RETURNING *;
+
@@ -0,0 +1,22 @@
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, created_at, moderator_id, action, chirp_id, user_id, reason, suspended_until)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: GetModerationActions :many
+SELECT *
+FROM moderation_actions
+WHERE (sqlc.narg(user_id)::UUID IS NULL OR user_id = sqlc.narg(user_id)::UUID)
+AND (sqlc.narg(chirp_id)::UUID IS NULL OR chirp_id = sqlc.narg(chirp_id)::UUID)
+AND (sqlc.narg(before)::TIMESTAMP IS NULL OR created_at < sqlc.narg(before)::TIMESTAMP)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results);
@@ -0,0 +1,65 @@
+-- name: CreateNotification :one
+-- Nothing is created for users notifying themselves, users who turned the
+-- notification type off, or when either user blocked the other.
+INSERT INTO notifications (id, created_at, user_id, actor_id, type, chirp_id)
+SELECT gen_random_uuid(), NOW(), sqlc.arg(user_id)::UUID, sqlc.arg(actor_id)::UUID, sqlc.arg(type)::TEXT, sqlc.narg(chirp_id)::UUID
+WHERE sqlc.arg(user_id)::UUID <> sqlc.arg(actor_id)::UUID
+AND NOT EXISTS (
+    SELECT 1
+    FROM notification_preferences
+    WHERE notification_preferences.user_id = sqlc.arg(user_id)::UUID
+    AND notification_preferences.type = sqlc.arg(type)::TEXT
+    AND NOT notification_preferences.enabled
+)
+AND NOT EXISTS (
+    SELECT 1
+    FROM blocks
+    WHERE (blocker_id = sqlc.arg(user_id)::UUID AND blocked_id = sqlc.arg(actor_id)::UUID)
+    OR (blocker_id = sqlc.arg(actor_id)::UUID AND blocked_id = sqlc.arg(user_id)::UUID)
+)
+RETURNING *;
+
+-- name: CreateWarningNotification :one
+-- Moderator warnings skip the checks in CreateNotification so that blocking
+-- the moderator doesn't hide them.
+INSERT INTO notifications (id, created_at, user_id, actor_id, type, chirp_id)
+VALUES (gen_random_uuid(), NOW(), $1, $2, 'warning', $3)
+RETURNING *;
+
+-- name: GetNotifications :many
+SELECT *
+FROM notifications
+WHERE user_id = sqlc.arg(user_id)
+AND (NOT sqlc.arg(unread_only)::BOOLEAN OR read_at IS NULL)
+AND (sqlc.narg(before)::TIMESTAMP IS NULL OR created_at < sqlc.narg(before)::TIMESTAMP)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*)
+FROM notifications
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1 AND user_id = $2;
+
+-- name: MarkAllNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: GetNotificationPreferences :many
+SELECT *
+FROM notification_preferences
+WHERE user_id = $1;
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled;
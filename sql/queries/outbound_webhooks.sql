@@ -0,0 +1,73 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, event_types)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT *
+FROM webhook_endpoints
+WHERE id = $1;
+
+-- name: GetUserWebhookEndpoints :many
+SELECT *
+FROM webhook_endpoints
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1 AND user_id = $2;
+
+-- name: EnqueueWebhookDeliveries :execrows
+-- Endpoints get events about their owner's account. Admins' endpoints also
+-- get the admin-only event types, such as signups, for every user.
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_type, payload, status, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), NOW(), webhook_endpoints.id, sqlc.arg(event_type), sqlc.arg(payload), 'pending', NOW()
+FROM webhook_endpoints
+JOIN users ON users.id = webhook_endpoints.user_id
+WHERE sqlc.arg(event_type)::TEXT = ANY(webhook_endpoints.event_types)
+AND (
+    webhook_endpoints.user_id = sqlc.arg(user_id)
+    OR (users.is_admin AND sqlc.arg(event_type)::TEXT = ANY(sqlc.arg(admin_event_types)::TEXT[]))
+);
+
+-- name: ClaimWebhookDeliveries :many
+-- Claimed deliveries are leased for five minutes so other workers skip them
+-- while they are being sent.
+UPDATE webhook_deliveries
+SET next_attempt_at = NOW() + INTERVAL '5 minutes', updated_at = NOW()
+WHERE id IN (
+    SELECT id
+    FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: FinishWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = $2,
+    attempts = attempts + 1,
+    next_attempt_at = $3,
+    last_status_code = $4,
+    last_error = $5,
+    delivered_at = CASE WHEN $2 = 'delivered' THEN NOW() ELSE delivered_at END,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT 100;
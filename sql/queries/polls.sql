@@ -0,0 +1,47 @@
+-- name: CreatePoll :one
+INSERT INTO polls (chirp_id, created_at, closes_at)
+VALUES (
+    $1,
+    NOW(),
+    $2
+)
+RETURNING *;
+
+-- name: CreatePollOptions :exec
+INSERT INTO poll_options (chirp_id, position, text)
+SELECT sqlc.arg(chirp_id), (options.ord - 1)::INTEGER, options.text
+FROM unnest(sqlc.arg(options)::TEXT[]) WITH ORDINALITY AS options(text, ord);
+
+-- name: GetPoll :one
+SELECT *
+FROM polls
+WHERE chirp_id = $1;
+
+-- name: GetPollsByChirpIDs :many
+SELECT *
+FROM polls
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::UUID[]);
+
+-- name: GetPollOptions :many
+-- Options of the given polls with their vote counts.
+SELECT poll_options.chirp_id, poll_options.position, poll_options.text, COUNT(poll_votes.user_id) AS votes
+FROM poll_options
+LEFT JOIN poll_votes ON poll_votes.chirp_id = poll_options.chirp_id AND poll_votes.position = poll_options.position
+WHERE poll_options.chirp_id = ANY(sqlc.arg(chirp_ids)::UUID[])
+GROUP BY poll_options.chirp_id, poll_options.position, poll_options.text
+ORDER BY poll_options.chirp_id, poll_options.position;
+
+-- name: GetPollVotes :many
+-- The votes a user cast in the given polls.
+SELECT *
+FROM poll_votes
+WHERE user_id = sqlc.arg(user_id) AND chirp_id = ANY(sqlc.arg(chirp_ids)::UUID[]);
+
+-- name: CastPollVote :execrows
+-- Nothing is recorded once the poll has closed or if the user already voted.
+INSERT INTO poll_votes (chirp_id, user_id, position, created_at)
+SELECT sqlc.arg(chirp_id), sqlc.arg(user_id), sqlc.arg(position), NOW()
+WHERE EXISTS (
+    SELECT 1 FROM polls WHERE polls.chirp_id = sqlc.arg(chirp_id) AND polls.closes_at > NOW()
+)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,26 @@
+-- name: UpdateUserProfile :one
+UPDATE users
+SET handle = $2, display_name = $3, bio = $4, avatar_url = $5, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetUserByHandle :one
+SELECT *
+FROM users
+WHERE handle = $1;
+
+-- name: GetUserStats :one
+SELECT
+    (SELECT COUNT(*) FROM chirps WHERE chirps.user_id = $1 AND chirps.scheduled_for IS NULL AND chirps.deleted_at IS NULL) AS chirp_count,
+    (SELECT COUNT(*) FROM follows WHERE follows.followee_id = $1) AS follower_count,
+    (SELECT COUNT(*) FROM follows WHERE follows.follower_id = $1) AS following_count;
+
+-- name: GetChirpAuthors :many
+SELECT id, handle, display_name, avatar_url
+FROM users
+WHERE id = ANY(sqlc.arg(ids)::UUID[]);
+
+-- name: GetUserIDsByHandles :many
+SELECT id
+FROM users
+WHERE handle = ANY(sqlc.arg(handles)::TEXT[]);
@@ -2,3 +2,8 @@
 UPDATE users
 SET is_chirpy_red = TRUE
 WHERE id = $1;
+
+-- name: DowngradeUser :exec
+UPDATE users
+SET is_chirpy_red = FALSE
+WHERE id = $1;
@@ -0,0 +1,33 @@
+-- name: CreateReport :one
+-- Nothing is created when the user already reported the chirp.
+INSERT INTO reports (id, created_at, chirp_id, reporter_id, reason, details)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING *;
+
+-- name: GetReportQueue :many
+-- Reported chirps waiting for a moderator, most reported first.
+SELECT chirp_id, COUNT(*) AS report_count, MIN(created_at)::TIMESTAMP AS first_reported_at
+FROM reports
+WHERE status = 'open' AND chirp_id IN (SELECT id FROM chirps WHERE deleted_at IS NULL)
+GROUP BY chirp_id
+ORDER BY report_count DESC, first_reported_at
+LIMIT sqlc.arg(max_results);
+
+-- name: GetOpenReports :many
+SELECT *
+FROM reports
+WHERE status = 'open' AND chirp_id = ANY(sqlc.arg(chirp_ids)::UUID[])
+ORDER BY created_at;
+
+-- name: ResolveReports :execrows
+UPDATE reports
+SET status = $2, resolved_at = NOW()
+WHERE chirp_id = $1 AND status = 'open';
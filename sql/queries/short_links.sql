@@ -0,0 +1,32 @@
+-- name: CreateShortLink :one
+-- Returns no rows if the code is already taken.
+INSERT INTO short_links (code, created_at, chirp_id, user_id, url)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (code) DO NOTHING
+RETURNING *;
+
+-- name: FollowShortLink :one
+-- Counts a click and returns where the link goes. Links in deleted chirps
+-- stop working.
+UPDATE short_links
+SET clicks = clicks + 1
+WHERE code = $1
+AND chirp_id IN (SELECT id FROM chirps WHERE deleted_at IS NULL)
+RETURNING url;
+
+-- name: GetChirpShortLinks :many
+SELECT *
+FROM short_links
+WHERE chirp_id = $1
+ORDER BY created_at, url;
+
+-- name: GetShortLink :one
+SELECT *
+FROM short_links
+WHERE code = $1;
@@ -0,0 +1,48 @@
+-- name: CreateSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, started_at, ends_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    'active',
+    NOW(),
+    $3
+)
+RETURNING *;
+
+-- name: GetCurrentSubscription :one
+SELECT *
+FROM subscriptions
+WHERE user_id = $1 AND status IN ('active', 'cancelled')
+ORDER BY started_at DESC
+LIMIT 1;
+
+-- name: GetUserSubscriptions :many
+SELECT *
+FROM subscriptions
+WHERE user_id = $1
+ORDER BY started_at DESC;
+
+-- name: RenewSubscription :one
+UPDATE subscriptions
+SET plan = $2, ends_at = $3, status = 'active', updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CancelSubscription :exec
+UPDATE subscriptions
+SET status = 'cancelled', updated_at = NOW()
+WHERE id = $1;
+
+-- name: EndSubscription :exec
+UPDATE subscriptions
+SET status = $2, ended_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: ExpireSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired', ended_at = NOW(), updated_at = NOW()
+WHERE status IN ('active', 'cancelled') AND ends_at <= NOW()
+RETURNING user_id;
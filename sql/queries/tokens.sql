@@ -19,3 +19,12 @@ UPDATE refresh_tokens
 SET updated_at = NOW(), revoked_at = NOw() 
 WHERE $1 = token;
 
+-- name: GetUserRefreshTokens :many
+SELECT *
+FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: DeleteUserRefreshTokens :exec
+DELETE FROM refresh_tokens
+WHERE user_id = $1;
@@ -17,3 +17,27 @@ UPDATE users
 SET email = $2, hashed_password = $3, updated_at = NOW()
 WHERE id = $1
 RETURNING *;
+
+-- name: GetUserByID :one
+SELECT *
+FROM users
+WHERE id = $1;
+
+-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = $1;
+
+-- name: SetUserSuspension :exec
+UPDATE users
+SET suspended_until = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetUserBan :exec
+UPDATE users
+SET banned_at = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetUserAccountStatus :one
+SELECT banned_at, suspended_until
+FROM users
+WHERE id = $1;
@@ -0,0 +1,45 @@
+-- name: RecordWebhookEvent :one
+INSERT INTO webhook_events (id, received_at, updated_at, event_type, payload, status)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3,
+    'pending'
+)
+ON CONFLICT (id) DO UPDATE SET updated_at = NOW()
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT *
+FROM webhook_events
+WHERE id = $1;
+
+-- name: LockWebhookEvent :one
+SELECT *
+FROM webhook_events
+WHERE id = $1
+FOR UPDATE;
+
+-- name: FinishWebhookEvent :exec
+UPDATE webhook_events
+SET status = $2, last_error = $3, attempts = attempts + 1, last_attempt_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: ListWebhookEvents :many
+SELECT *
+FROM webhook_events
+WHERE (sqlc.arg(status)::TEXT = '' OR status = sqlc.arg(status))
+ORDER BY received_at DESC
+LIMIT 100;
+
+-- name: RecordWebhookSignature :execrows
+-- Returns 0 rows if the signature was already recorded.
+INSERT INTO webhook_signatures (signature, expires_at)
+VALUES ($1, $2)
+ON CONFLICT (signature) DO NOTHING;
+
+-- name: DeleteExpiredWebhookSignatures :exec
+DELETE FROM webhook_signatures
+WHERE expires_at < NOW();
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN handle TEXT UNIQUE DEFAULT NULL,
+ADD COLUMN display_name TEXT NOT NULL DEFAULT '',
+ADD COLUMN bio TEXT NOT NULL DEFAULT '',
+ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN handle,
+DROP COLUMN display_name,
+DROP COLUMN bio,
+DROP COLUMN avatar_url;
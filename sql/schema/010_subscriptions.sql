@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    id          UUID PRIMARY KEY,
+    created_at  TIMESTAMP NOT NULL,
+    updated_at  TIMESTAMP NOT NULL,
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    plan        TEXT NOT NULL,
+    status      TEXT NOT NULL,
+    started_at  TIMESTAMP NOT NULL,
+    ends_at     TIMESTAMP DEFAULT NULL,
+    ended_at    TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX subscriptions_user_id_idx ON subscriptions(user_id);
+
+-- +goose Down
+DROP TABLE subscriptions;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+    id              TEXT PRIMARY KEY,
+    received_at     TIMESTAMP NOT NULL,
+    updated_at      TIMESTAMP NOT NULL,
+    event_type      TEXT NOT NULL,
+    payload         TEXT NOT NULL,
+    status          TEXT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT DEFAULT NULL,
+    last_attempt_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX webhook_events_status_idx ON webhook_events(status);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE webhook_endpoints (
+    id          UUID PRIMARY KEY,
+    created_at  TIMESTAMP NOT NULL,
+    updated_at  TIMESTAMP NOT NULL,
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url         TEXT NOT NULL,
+    secret      TEXT NOT NULL,
+    event_types TEXT[] NOT NULL
+);
+
+CREATE TABLE webhook_deliveries (
+    id               UUID PRIMARY KEY,
+    created_at       TIMESTAMP NOT NULL,
+    updated_at       TIMESTAMP NOT NULL,
+    endpoint_id      UUID NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    event_type       TEXT NOT NULL,
+    payload          TEXT NOT NULL,
+    status           TEXT NOT NULL,
+    attempts         INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at  TIMESTAMP NOT NULL,
+    last_status_code INTEGER DEFAULT NULL,
+    last_error       TEXT DEFAULT NULL,
+    delivered_at     TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_endpoint_idx ON webhook_deliveries (endpoint_id, created_at);
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_endpoints;
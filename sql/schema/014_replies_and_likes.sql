@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN in_reply_to UUID DEFAULT NULL REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE INDEX chirps_in_reply_to_idx ON chirps (in_reply_to);
+
+CREATE TABLE likes (
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id   UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+
+-- +goose Down
+DROP TABLE likes;
+ALTER TABLE chirps DROP COLUMN in_reply_to;
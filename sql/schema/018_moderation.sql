@@ -0,0 +1,44 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN hidden_at TIMESTAMP DEFAULT NULL;
+
+ALTER TABLE users
+ADD COLUMN suspended_until TIMESTAMP DEFAULT NULL;
+
+CREATE TABLE reports (
+    id          UUID PRIMARY KEY,
+    created_at  TIMESTAMP NOT NULL,
+    chirp_id    UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason      TEXT NOT NULL,
+    details     TEXT NOT NULL DEFAULT '',
+    status      TEXT NOT NULL DEFAULT 'open',
+    resolved_at TIMESTAMP DEFAULT NULL,
+    UNIQUE (chirp_id, reporter_id)
+);
+
+CREATE INDEX reports_open_idx ON reports (chirp_id) WHERE status = 'open';
+
+-- Moderator decisions are kept after the chirp or moderator is deleted.
+CREATE TABLE moderation_actions (
+    id              UUID PRIMARY KEY,
+    created_at      TIMESTAMP NOT NULL,
+    moderator_id    UUID REFERENCES users(id) ON DELETE SET NULL,
+    action          TEXT NOT NULL,
+    chirp_id        UUID,
+    user_id         UUID REFERENCES users(id) ON DELETE CASCADE,
+    reason          TEXT NOT NULL DEFAULT '',
+    suspended_until TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX moderation_actions_user_idx ON moderation_actions (user_id, created_at);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+
+ALTER TABLE users
+DROP COLUMN suspended_until;
+
+ALTER TABLE chirps
+DROP COLUMN hidden_at;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN banned_at TIMESTAMP DEFAULT NULL;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN banned_at;
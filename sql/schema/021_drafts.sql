@@ -0,0 +1,14 @@
+-- +goose Up
+-- A draft with several parts is published as a thread.
+CREATE TABLE drafts (
+    id         UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parts      TEXT[] NOT NULL
+);
+
+CREATE INDEX drafts_user_idx ON drafts (user_id, updated_at);
+
+-- +goose Down
+DROP TABLE drafts;
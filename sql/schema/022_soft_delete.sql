@@ -0,0 +1,16 @@
+-- +goose Up
+-- Deleted chirps are kept for a while so they can be restored and so
+-- moderators keep the evidence. deleted_by tells owner deletions apart
+-- from moderator removals.
+ALTER TABLE chirps
+ADD COLUMN deleted_at TIMESTAMP DEFAULT NULL,
+ADD COLUMN deleted_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX chirps_deleted_at_idx ON chirps (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_deleted_at_idx;
+
+ALTER TABLE chirps
+DROP COLUMN deleted_by,
+DROP COLUMN deleted_at;
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE bookmarks (
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id   UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX bookmarks_user_idx ON bookmarks (user_id, created_at);
+
+-- Lists are curated timelines of accounts. Private lists are only visible
+-- to their owner.
+CREATE TABLE lists (
+    id          UUID PRIMARY KEY,
+    created_at  TIMESTAMP NOT NULL,
+    updated_at  TIMESTAMP NOT NULL,
+    owner_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name        TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    visibility  TEXT NOT NULL DEFAULT 'public'
+);
+
+CREATE INDEX lists_owner_idx ON lists (owner_id, created_at);
+
+CREATE TABLE list_members (
+    list_id    UUID NOT NULL REFERENCES lists(id) ON DELETE CASCADE,
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (list_id, user_id)
+);
+
+-- +goose Down
+DROP TABLE list_members;
+DROP TABLE lists;
+DROP TABLE bookmarks;
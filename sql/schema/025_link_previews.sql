@@ -0,0 +1,28 @@
+-- +goose Up
+-- Previews are cached per URL and shared by every chirp that links to it.
+-- Pending previews are fetched in the background.
+CREATE TABLE link_previews (
+    url             TEXT PRIMARY KEY,
+    created_at      TIMESTAMP NOT NULL,
+    updated_at      TIMESTAMP NOT NULL,
+    status          TEXT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    title           TEXT NOT NULL DEFAULT '',
+    description     TEXT NOT NULL DEFAULT '',
+    image_url       TEXT NOT NULL DEFAULT '',
+    fetched_at      TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX link_previews_due_idx ON link_previews (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE chirp_links (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    url      TEXT NOT NULL REFERENCES link_previews(url),
+    PRIMARY KEY (chirp_id, position)
+);
+
+-- +goose Down
+DROP TABLE chirp_links;
+DROP TABLE link_previews;
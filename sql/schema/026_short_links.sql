@@ -0,0 +1,16 @@
+-- +goose Up
+-- Links in chirps are rewritten to /l/{code}. Each chirp gets its own codes
+-- so that authors see the clicks on their own chirps.
+CREATE TABLE short_links (
+    code       TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    chirp_id   UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url        TEXT NOT NULL,
+    clicks     BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX short_links_chirp_idx ON short_links (chirp_id);
+
+-- +goose Down
+DROP TABLE short_links;
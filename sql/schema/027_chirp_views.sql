@@ -0,0 +1,12 @@
+-- +goose Up
+-- Views are counted in memory and added here in batches, one row per chirp
+-- per hour.
+CREATE TABLE chirp_views (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    hour     TIMESTAMP NOT NULL,
+    views    BIGINT NOT NULL,
+    PRIMARY KEY (chirp_id, hour)
+);
+
+-- +goose Down
+DROP TABLE chirp_views;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Rebuilt periodically by the ranking job from likes, replies and views.
+-- Only recent chirps are scored.
+CREATE TABLE chirp_scores (
+    chirp_id       UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+    computed_at    TIMESTAMP NOT NULL,
+    engagement     DOUBLE PRECISION NOT NULL,
+    trending_score DOUBLE PRECISION NOT NULL
+);
+
+CREATE INDEX chirp_scores_engagement_idx ON chirp_scores (engagement DESC);
+CREATE INDEX chirp_scores_trending_idx ON chirp_scores (trending_score DESC);
+
+-- +goose Down
+DROP TABLE chirp_scores;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Signatures of accepted Polka deliveries, kept until their timestamp could
+-- no longer pass verification so that replays are refused on every
+-- instance and across restarts.
+CREATE TABLE webhook_signatures (
+    signature  TEXT PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX webhook_signatures_expires_idx ON webhook_signatures (expires_at);
+
+-- +goose Down
+DROP TABLE webhook_signatures;
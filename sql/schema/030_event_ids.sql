@@ -0,0 +1,7 @@
+-- +goose Up
+-- Live events take their IDs from here so that they are ordered the same way
+-- on every instance and clients can resume a stream on any of them.
+CREATE SEQUENCE event_ids;
+
+-- +goose Down
+DROP SEQUENCE event_ids;
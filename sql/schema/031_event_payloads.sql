@@ -0,0 +1,12 @@
+-- +goose Up
+-- Events too large for a NOTIFY payload are stored here and read back by the
+-- other instances. Rows are only needed for a moment and are removed as new
+-- ones are added.
+CREATE TABLE event_payloads (
+    id         BIGINT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    data       BYTEA NOT NULL
+);
+
+-- +goose Down
+DROP TABLE event_payloads;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Every user gets a handle so that embedded authors always have one. Users
+-- without a handle of their own get a generated one they can change.
+UPDATE users
+SET handle = 'user_' || substr(md5(id::TEXT), 1, 10)
+WHERE handle IS NULL;
+
+ALTER TABLE users
+ALTER COLUMN handle SET DEFAULT 'user_' || substr(md5(gen_random_uuid()::TEXT), 1, 10);
+
+-- +goose Down
+ALTER TABLE users
+ALTER COLUMN handle DROP DEFAULT;
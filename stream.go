@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/events"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+const streamHeartbeat = 15 * time.Second
+
+// Events that are pushed to /api/stream.
+var streamEventTypes = []string{eventChirpCreated, eventChirpDeleted}
+
+// publishEvent announces an event to live streams on every instance and to
+// outbound webhooks.
+// userID is the account the event is about.
+func (cfg *apiConfig) publishEvent(ctx context.Context, eventType string, userID uuid.UUID, data any) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error encoding %s event: %v", eventType, err)
+		return
+	}
+	if err := cfg.eventRelay.Publish(ctx, eventType, userID, encoded); err != nil {
+		log.Printf("Error relaying %s event: %v", eventType, err)
+	}
+	cfg.emitWebhookEvent(ctx, eventType, userID, data)
+}
+
+var (
+	errUnknownFeed     = errors.New("unknown feed")
+	errFeedNeedsAuthor = errors.New("feed needs a valid author_id")
+	errFeedNeedsAuth   = errors.New("feed needs an authenticated user")
+)
+
+// feedAuthors resolves a feed to the authors whose chirps it shows: every
+// author for "global", one for "author", and the user with everyone they
+// follow for "timeline". A nil slice means every author.
+func (cfg *apiConfig) feedAuthors(ctx context.Context, feed, authorID string, userID uuid.UUID) ([]uuid.UUID, error) {
+	switch feed {
+	case "", "global":
+		return nil, nil
+	case "author":
+		id, err := uuid.Parse(authorID)
+		if err != nil {
+			return nil, errFeedNeedsAuthor
+		}
+		return []uuid.UUID{id}, nil
+	case "timeline":
+		if userID == uuid.Nil {
+			return nil, errFeedNeedsAuth
+		}
+		followees, err := cfg.dbQueries.GetFolloweeIDs(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		return append(followees, userID), nil
+	default:
+		return nil, errUnknownFeed
+	}
+}
+
+func feedErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, errUnknownFeed):
+		return http.StatusBadRequest, "Unknown feed"
+	case errors.Is(err, errFeedNeedsAuthor):
+		return http.StatusBadRequest, "Invalid author ID"
+	case errors.Is(err, errFeedNeedsAuth):
+		return http.StatusUnauthorized, "Invalid Access Token"
+	default:
+		return http.StatusInternalServerError, "Could not get the feed"
+	}
+}
+
+// feedFilter selects the chirp events shown in a feed of the given authors,
+// leaving out those by users hidden from the viewer.
+func feedFilter(authors, hidden []uuid.UUID) events.Filter {
+	return func(e events.Event) bool {
+		if !slices.Contains(streamEventTypes, e.Type) || slices.Contains(hidden, e.UserID) {
+			return false
+		}
+		return authors == nil || slices.Contains(authors, e.UserID)
+	}
+}
+
+// lastEventID reads where a reconnecting client left off. Browsers send the
+// Last-Event-ID header themselves; the query parameter is for clients that
+// can't set headers.
+func lastEventID(r *http.Request) int64 {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	id, _ := strconv.ParseInt(value, 10, 64)
+	return id
+}
+
+func writeStreamEvent(w http.ResponseWriter, event events.Event) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+	return err
+}
+
+func (cfg *apiConfig) handlerStream(w http.ResponseWriter, r *http.Request) {
+	// Streams the global feed by default, a single author's chirps with
+	// ?author_id, or the authenticated user's timeline with ?feed=timeline.
+	// Authenticated viewers don't see chirps by users they blocked or muted.
+	query := r.URL.Query()
+	feed := query.Get("feed")
+	if query.Get("author_id") != "" {
+		feed = "author"
+	}
+	userID, err := cfg.viewer(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+	authors, err := cfg.feedAuthors(r.Context(), feed, query.Get("author_id"), userID)
+	if err != nil {
+		status, message := feedErrorStatus(err)
+		w.Header().Set("Content-Type", "application/json")
+		utils.RespondWithError(w, status, message)
+		return
+	}
+	hidden, err := cfg.hiddenUsers(r.Context(), userID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the feed")
+		return
+	}
+
+	// Authenticated streams also listen for their user being banned or
+	// suspended, which ends the stream.
+	filter := feedFilter(authors, hidden)
+	sub, missed := cfg.events.Subscribe(func(e events.Event) bool {
+		if e.Type == eventAccountDisabled {
+			return userID != uuid.Nil && e.UserID == userID
+		}
+		return filter(e)
+	}, lastEventID(r))
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	for _, event := range missed {
+		if event.Type == eventAccountDisabled {
+			continue
+		}
+		if err := writeStreamEvent(w, event); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			// The hub drops subscribers that fall behind, the client
+			// reconnects and resumes from its last event.
+			if !ok || event.Type == eventAccountDisabled {
+				return
+			}
+			if err := writeStreamEvent(w, event); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
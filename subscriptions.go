@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/auth"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+// Polka webhook events that change a user's Chirpy Red membership.
+const (
+	polkaUserUpgraded   = "user.upgraded"
+	polkaUserDowngraded = "user.downgraded"
+	polkaUserCancelled  = "user.cancelled"
+	polkaUserRefunded   = "user.refunded"
+)
+
+const (
+	planMonthly  = "monthly"
+	planYearly   = "yearly"
+	planLifetime = "lifetime"
+)
+
+var errUnknownPlan = errors.New("unknown subscription plan")
+
+type Subscription struct {
+	ID        uuid.UUID  `json:"id"`
+	Plan      string     `json:"plan"`
+	Status    string     `json:"status"`
+	StartedAt time.Time  `json:"started_at"`
+	EndsAt    *time.Time `json:"ends_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+}
+
+func subscriptionFromDB(dbSub database.Subscription) Subscription {
+	return Subscription{
+		ID:        dbSub.ID,
+		Plan:      dbSub.Plan,
+		Status:    dbSub.Status,
+		StartedAt: dbSub.StartedAt,
+		EndsAt:    nullTimePtr(dbSub.EndsAt),
+		EndedAt:   nullTimePtr(dbSub.EndedAt),
+	}
+}
+
+// subscriptionEnd works out when a paid period ends. Polka may send the end
+// date itself, otherwise it follows from the plan, starting at from.
+func subscriptionEnd(plan string, endsAt *time.Time, from time.Time) (sql.NullTime, error) {
+	if endsAt != nil {
+		return sql.NullTime{Time: *endsAt, Valid: true}, nil
+	}
+
+	switch plan {
+	case planMonthly:
+		return sql.NullTime{Time: from.AddDate(0, 1, 0), Valid: true}, nil
+	case planYearly:
+		return sql.NullTime{Time: from.AddDate(1, 0, 0), Valid: true}, nil
+	case planLifetime:
+		return sql.NullTime{}, nil
+	default:
+		return sql.NullTime{}, errUnknownPlan
+	}
+}
+
+// applySubscriptionEvent records a Polka membership event and keeps the
+// user's is_chirpy_red flag in sync with it. qtx should be bound to a
+// transaction.
+func applySubscriptionEvent(ctx context.Context, qtx *database.Queries, userID uuid.UUID, event, plan string, endsAt *time.Time) error {
+	if plan == "" {
+		plan = planMonthly
+	}
+
+	current, err := qtx.GetCurrentSubscription(ctx, userID)
+	hasCurrent := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	switch event {
+	case polkaUserUpgraded:
+		// A renewal extends the running period rather than restarting it.
+		from := time.Now()
+		if hasCurrent && current.EndsAt.Valid && current.EndsAt.Time.After(from) {
+			from = current.EndsAt.Time
+		}
+		end, err := subscriptionEnd(plan, endsAt, from)
+		if err != nil {
+			return err
+		}
+
+		if hasCurrent {
+			_, err = qtx.RenewSubscription(ctx, database.RenewSubscriptionParams{
+				ID:     current.ID,
+				Plan:   plan,
+				EndsAt: end,
+			})
+		} else {
+			_, err = qtx.CreateSubscription(ctx, database.CreateSubscriptionParams{
+				UserID: userID,
+				Plan:   plan,
+				EndsAt: end,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		if err := qtx.UpgradeUser(ctx, userID); err != nil {
+			return err
+		}
+
+	case polkaUserCancelled:
+		// Cancelled memberships stay active until the paid period runs out
+		// and the expiry job picks them up. Members without a subscription
+		// have no period to run out, so they are downgraded right away.
+		if !hasCurrent {
+			if err := qtx.DowngradeUser(ctx, userID); err != nil {
+				return err
+			}
+			break
+		}
+		if current.EndsAt.Valid {
+			if err := qtx.CancelSubscription(ctx, current.ID); err != nil {
+				return err
+			}
+			break
+		}
+		if err := qtx.EndSubscription(ctx, database.EndSubscriptionParams{ID: current.ID, Status: "cancelled"}); err != nil {
+			return err
+		}
+		if err := qtx.DowngradeUser(ctx, userID); err != nil {
+			return err
+		}
+
+	case polkaUserDowngraded, polkaUserRefunded:
+		if hasCurrent {
+			status := "downgraded"
+			if event == polkaUserRefunded {
+				status = "refunded"
+			}
+			if err := qtx.EndSubscription(ctx, database.EndSubscriptionParams{ID: current.ID, Status: status}); err != nil {
+				return err
+			}
+		}
+		if err := qtx.DowngradeUser(ctx, userID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) error {
+	userIDs, err := cfg.dbQueries.ExpireSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		_, err := cfg.dbQueries.GetCurrentSubscription(ctx, userID)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err := cfg.dbQueries.DowngradeUser(ctx, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runSubscriptionExpiry periodically ends memberships whose paid period has
+// lapsed.
+func (cfg *apiConfig) runSubscriptionExpiry(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := cfg.expireSubscriptions(context.Background()); err != nil {
+			log.Printf("Error expiring subscriptions: %v", err)
+		}
+	}
+}
+
+func (cfg *apiConfig) handlerGetSubscription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbUser, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User could not be found")
+		return
+	}
+	dbSubs, err := cfg.dbQueries.GetUserSubscriptions(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get subscriptions")
+		return
+	}
+
+	response := struct {
+		IsRed   bool           `json:"is_chirpy_red"`
+		Current *Subscription  `json:"current"`
+		History []Subscription `json:"history"`
+	}{
+		IsRed:   dbUser.IsChirpyRed,
+		History: make([]Subscription, 0, len(dbSubs)),
+	}
+	for _, dbSub := range dbSubs {
+		sub := subscriptionFromDB(dbSub)
+		if response.Current == nil && (sub.Status == "active" || sub.Status == "cancelled") {
+			response.Current = &sub
+		}
+		response.History = append(response.History, sub)
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCancelWithoutSubscriptionDowngrades(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+	dbUser, _ := createTestUser(t, cfg)
+
+	// Members upgraded before subscriptions were tracked have no
+	// subscription row.
+	if err := cfg.dbQueries.UpgradeUser(ctx, dbUser.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := applySubscriptionEvent(ctx, cfg.dbQueries, dbUser.ID, polkaUserCancelled, "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	dbUser, err := cfg.dbQueries.GetUserByID(ctx, dbUser.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbUser.IsChirpyRed {
+		t.Error("expected the user to be downgraded")
+	}
+}
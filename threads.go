@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+// maxThreadParts is the most chirps a thread or draft can have.
+const maxThreadParts = 25
+
+// validateThreadParts checks that a thread has between one and
+// maxThreadParts parts and that each one fits in a chirp.
+func validateThreadParts(parts []string, maxLength int) error {
+	if len(parts) == 0 || len(parts) > maxThreadParts {
+		return fmt.Errorf("a thread needs between 1 and %d parts", maxThreadParts)
+	}
+	for i, part := range parts {
+		if part == "" {
+			return fmt.Errorf("part %d is empty", i+1)
+		}
+		if chirpLength(part) > maxLength {
+			return fmt.Errorf("part %d is too long, the limit is %d characters", i+1, maxLength)
+		}
+	}
+	return nil
+}
+
+// createThread creates the parts as a chain of chirps in which each chirp
+// replies to the one before it. The first chirp replies to inReplyTo when
+// it is set. Chirps in a thread share their created_at, so the chain is
+// what orders them.
+func (cfg *apiConfig) createThread(ctx context.Context, qtx *database.Queries, userID uuid.UUID, parts []string, inReplyTo uuid.NullUUID) ([]database.Chirp, error) {
+	dbChirps := make([]database.Chirp, 0, len(parts))
+	for _, part := range parts {
+		dbChirp, err := qtx.CreateChirps(ctx, database.CreateChirpsParams{
+			Body:      cleanChirp(part),
+			UserID:    userID,
+			InReplyTo: inReplyTo,
+		})
+		if err != nil {
+			return nil, err
+		}
+		dbChirp, err = cfg.storeLinks(ctx, qtx, dbChirp)
+		if err != nil {
+			return nil, err
+		}
+		dbChirps = append(dbChirps, dbChirp)
+		inReplyTo = uuid.NullUUID{UUID: dbChirp.ID, Valid: true}
+	}
+	return dbChirps, nil
+}
+
+func (cfg *apiConfig) handlerCreateThread(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	var params struct {
+		Parts     []string   `json:"parts"`
+		InReplyTo *uuid.UUID `json:"in_reply_to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	ent, err := cfg.userEntitlements(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+	if !cfg.allowWrite(w, userID, ent) {
+		return
+	}
+	if err := validateThreadParts(params.Parts, ent.MaxChirpLength); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	inReplyTo, ok := cfg.replyTarget(w, r, userID, params.InReplyTo)
+	if !ok {
+		return
+	}
+
+	// Either every part of the thread is created or none is.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create the thread")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	dbChirps, err := cfg.createThread(r.Context(), qtx, userID, params.Parts, inReplyTo)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create the thread")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create the thread")
+		return
+	}
+
+	chirps, err := cfg.announceChirps(r.Context(), dbChirps)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the Chirps' authors")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, chirps)
+}
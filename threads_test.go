@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateThreadParts(t *testing.T) {
+	tests := []struct {
+		name    string
+		parts   []string
+		wantErr bool
+	}{
+		{"single part", []string{"hello"}, false},
+		{"several parts", []string{"one", "two", "three"}, false},
+		{"no parts", nil, true},
+		{"empty part", []string{"one", ""}, true},
+		{"part too long", []string{"one", strings.Repeat("a", 141)}, true},
+		{"too many parts", make([]string, maxThreadParts+1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateThreadParts(tt.parts, 140)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCreateThreadChainsParts(t *testing.T) {
+	cfg := newTestConfig(t)
+	_, token := createTestUser(t, cfg)
+
+	rec := serveTestRecorder(cfg.handlerCreateThread, http.MethodPost, "/api/chirps/thread", "/api/chirps/thread", token, `{"parts":["one","two","three"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	var chirps []Chirp
+	if err := json.NewDecoder(rec.Body).Decode(&chirps); err != nil {
+		t.Fatal(err)
+	}
+	if len(chirps) != 3 {
+		t.Fatalf("expected 3 chirps, got %d", len(chirps))
+	}
+	if chirps[0].InReplyTo != nil {
+		t.Errorf("expected the first part not to be a reply, got %v", *chirps[0].InReplyTo)
+	}
+	for i, body := range []string{"one", "two", "three"} {
+		if chirps[i].Body != body {
+			t.Errorf("expected part %d to be %q, got %q", i+1, body, chirps[i].Body)
+		}
+		if i > 0 && (chirps[i].InReplyTo == nil || *chirps[i].InReplyTo != chirps[i-1].ID) {
+			t.Errorf("expected part %d to reply to part %d", i+1, i)
+		}
+	}
+}
+
+func TestCreateThreadRejectsInvalidParts(t *testing.T) {
+	cfg := newTestConfig(t)
+	user, token := createTestUser(t, cfg)
+
+	status := serveTestRequest(cfg.handlerCreateThread, http.MethodPost, "/api/chirps/thread", "/api/chirps/thread", token, `{"parts":["one",""]}`)
+	if status != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", status)
+	}
+	// Nothing is created when the thread is rejected.
+	rec := serveTestRecorder(cfg.handlerGetAllChirps, http.MethodGet, "/api/chirps", "/api/chirps?author_id="+user.ID.String(), token, "")
+	var chirps []Chirp
+	if err := json.NewDecoder(rec.Body).Decode(&chirps); err != nil {
+		t.Fatal(err)
+	}
+	if len(chirps) != 0 {
+		t.Errorf("expected no chirps, got %d", len(chirps))
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+const (
+	// chirpRestoreWindow is how long owners can restore a deleted chirp.
+	chirpRestoreWindow = 30 * 24 * time.Hour
+	// moderatedChirpRetention is how long chirps removed by a moderator are
+	// kept before they are purged.
+	moderatedChirpRetention = 180 * 24 * time.Hour
+)
+
+// purgeDeletedChirps permanently removes soft-deleted chirps that are past
+// their retention period.
+func (cfg *apiConfig) purgeDeletedChirps(ctx context.Context) error {
+	now := time.Now().UTC()
+	ownerDeletedBefore := now.Add(-chirpRestoreWindow)
+	moderatorDeletedBefore := now.Add(-moderatedChirpRetention)
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	// Media rows cascade with their chirps, their files are deleted once
+	// the chirps are gone.
+	dbMedia, err := qtx.GetPurgeableMediaFiles(ctx, database.GetPurgeableMediaFilesParams{
+		OwnerDeletedBefore:     ownerDeletedBefore,
+		ModeratorDeletedBefore: moderatorDeletedBefore,
+	})
+	if err != nil {
+		return err
+	}
+	purged, err := qtx.PurgeDeletedChirps(ctx, database.PurgeDeletedChirpsParams{
+		OwnerDeletedBefore:     ownerDeletedBefore,
+		ModeratorDeletedBefore: moderatorDeletedBefore,
+	})
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	cfg.deleteMediaBlobs(ctx, dbMedia)
+	if purged > 0 {
+		log.Printf("Purged %d deleted chirps", purged)
+	}
+	return nil
+}
+
+// runChirpPurge periodically purges expired soft-deleted chirps.
+func (cfg *apiConfig) runChirpPurge(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := cfg.purgeDeletedChirps(context.Background()); err != nil {
+			log.Printf("Error purging deleted chirps: %v", err)
+		}
+	}
+}
+
+func (cfg *apiConfig) handlerGetDeletedChirps(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := cfg.authenticate(r, scopeChirpsRead)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbChirps, err := cfg.dbQueries.GetDeletedChirps(r.Context(), database.GetDeletedChirpsParams{
+		UserID:       userID,
+		DeletedAfter: time.Now().UTC().Add(-chirpRestoreWindow),
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get deleted chirps")
+		return
+	}
+	chirps, err := cfg.chirpsFromDB(r.Context(), dbChirps)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get deleted chirps")
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, chirps)
+}
+
+func (cfg *apiConfig) handlerRestoreChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid chirp ID")
+		return
+	}
+
+	userID, err := cfg.authenticate(r, scopeChirpsWrite)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	// Chirps removed by a moderator, or deleted longer ago than the restore
+	// window, can't be restored.
+	dbChirp, err := cfg.dbQueries.RestoreChirp(r.Context(), database.RestoreChirpParams{
+		ID:           chirpID,
+		UserID:       userID,
+		DeletedAfter: time.Now().UTC().Add(-chirpRestoreWindow),
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "No restorable chirp found")
+		return
+	}
+
+	chirp, err := cfg.chirpFromDB(r.Context(), dbChirp)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get the Chirp's author")
+		return
+	}
+	// Live feeds dropped the chirp when it was deleted. Mentioned users were
+	// already notified, so only the event is sent again.
+	if !dbChirp.ScheduledFor.Valid {
+		cfg.publishEvent(r.Context(), eventChirpCreated, userID, chirp)
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, chirp)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// deleteTestChirp soft-deletes a chirp as its owner, as if it happened age
+// ago.
+func deleteTestChirp(t *testing.T, cfg *apiConfig, chirpID uuid.UUID, token string, age time.Duration) {
+	t.Helper()
+	status := serveTestRequest(cfg.handlerDeleteChirp, http.MethodDelete, "/api/chirps/{chirpID}", "/api/chirps/"+chirpID.String(), token, "")
+	if status != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", status)
+	}
+	if _, err := cfg.db.ExecContext(context.Background(), "UPDATE chirps SET deleted_at = NOW() - $2 * INTERVAL '1 second' WHERE id = $1", chirpID, age.Seconds()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestoreChirpWithinWindow(t *testing.T) {
+	cfg := newTestConfig(t)
+	author, token := createTestUser(t, cfg)
+	_, otherToken := createTestUser(t, cfg)
+	dbChirp := createTestChirp(t, cfg, author.ID, "oops")
+	deleteTestChirp(t, cfg, dbChirp.ID, token, 24*time.Hour)
+
+	path := "/api/chirps/" + dbChirp.ID.String()
+	if status := serveTestRequest(cfg.handlerGetChirp, http.MethodGet, "/api/chirps/{chirpID}", path, otherToken, ""); status != http.StatusNotFound {
+		t.Errorf("expected 404 for a deleted chirp, got %d", status)
+	}
+	if status := serveTestRequest(cfg.handlerRestoreChirp, http.MethodPost, "/api/chirps/{chirpID}/restore", path+"/restore", otherToken, ""); status != http.StatusNotFound {
+		t.Errorf("expected 404 restoring another user's chirp, got %d", status)
+	}
+	if status := serveTestRequest(cfg.handlerRestoreChirp, http.MethodPost, "/api/chirps/{chirpID}/restore", path+"/restore", token, ""); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if status := serveTestRequest(cfg.handlerGetChirp, http.MethodGet, "/api/chirps/{chirpID}", path, otherToken, ""); status != http.StatusOK {
+		t.Errorf("expected 200 once restored, got %d", status)
+	}
+}
+
+func TestRestoreChirpOutsideWindowFails(t *testing.T) {
+	cfg := newTestConfig(t)
+	author, token := createTestUser(t, cfg)
+	dbChirp := createTestChirp(t, cfg, author.ID, "long gone")
+	deleteTestChirp(t, cfg, dbChirp.ID, token, chirpRestoreWindow+time.Hour)
+
+	path := "/api/chirps/" + dbChirp.ID.String() + "/restore"
+	if status := serveTestRequest(cfg.handlerRestoreChirp, http.MethodPost, "/api/chirps/{chirpID}/restore", path, token, ""); status != http.StatusNotFound {
+		t.Errorf("expected 404 outside the restore window, got %d", status)
+	}
+
+	if err := cfg.purgeDeletedChirps(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var id uuid.UUID
+	err := cfg.db.QueryRowContext(context.Background(), "SELECT id FROM chirps WHERE id = $1", dbChirp.ID).Scan(&id)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected the chirp to be purged, got %v", err)
+	}
+}
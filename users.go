@@ -11,54 +11,32 @@ import (
 	"github.com/tsyrdev/chirpy/utils"
 )
 
+const accessTokenTTL = time.Hour
+
 type User struct {
 	ID 			uuid.UUID 	`json:"id"`
 	CreatedAt	time.Time 	`json:"created_at"`
 	UpdatedAt	time.Time 	`json:"updated_at"`
 	Email		string		`json:"email"`
 	IsRed		bool		`json:"is_chirpy_red"`
+	Handle		string		`json:"handle"`
+	DisplayName	string		`json:"display_name"`
+	Bio			string		`json:"bio"`
+	AvatarURL	string		`json:"avatar_url"`
 }
 
-func (cfg *apiConfig) handlerUpgradeUser(w http.ResponseWriter, r *http.Request) {
-	defer r.Body.Close()
-
-	apiKey, err := auth.GetAPIKey(r.Header)
-	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		return 
-	}
-
-	if apiKey != cfg.polkaKey {
-		w.WriteHeader(http.StatusUnauthorized)
-		return 
-	}
-	
-	var params struct {
-		Event	string	`json:"event"`
-		Data	struct{
-			UserID	string	`json:"user_id"`
-		} `json:"data"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return 
-	}
-	if params.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
-		return
-	}
-
-	userID, err := uuid.Parse(params.Data.UserID)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return 
-	}
-	if err := cfg.dbQueries.UpgradeUser(r.Context(), userID); err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		return 
+func userFromDB(dbUser database.User) User {
+	return User{
+		ID:				dbUser.ID,
+		CreatedAt:		dbUser.CreatedAt,
+		UpdatedAt:		dbUser.UpdatedAt,
+		Email:			dbUser.Email,
+		IsRed:			dbUser.IsChirpyRed,
+		Handle:			dbUser.Handle.String,
+		DisplayName:	dbUser.DisplayName,
+		Bio:			dbUser.Bio,
+		AvatarURL:		dbUser.AvatarUrl,
 	}
-
-	w.WriteHeader(http.StatusNoContent)
 }
 
 func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request) {
@@ -103,14 +81,7 @@ func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 		return 
 	}
 
-	response := User{
-		ID:			dbUser.ID,
-		CreatedAt:	dbUser.CreatedAt,		
-		UpdatedAt:	dbUser.UpdatedAt,	
-		Email:		dbUser.Email,		
-		IsRed: 		dbUser.IsChirpyRed,
-	}
-	utils.RespondWithJSON(w, http.StatusOK, response)
+	utils.RespondWithJSON(w, http.StatusOK, userFromDB(dbUser))
 }
 
 func (cfg *apiConfig) handlerRevokeRefresh(w http.ResponseWriter, r *http.Request) {
@@ -146,8 +117,12 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusUnauthorized, "The refresh token has been revoked")
 		return 
 	}
+	if err := cfg.checkAccountStatus(r.Context(), dbToken.UserID); err != nil {
+		respondWithAccountError(w, err)
+		return
+	}
 
-	accessToken, err := auth.MakeJWT(dbToken.UserID, cfg.secret)
+	accessToken, err := auth.MakeJWT(dbToken.UserID, cfg.secret, accessTokenTTL)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Coudln't create a new access token")
 		return
@@ -185,8 +160,12 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
 		return 
 	}
+	if err := cfg.checkAccountStatus(r.Context(), dbUser.ID); err != nil {
+		respondWithAccountError(w, err)
+		return
+	}
 
-	token, err := auth.MakeJWT(dbUser.ID, cfg.secret)
+	token, err := auth.MakeJWT(dbUser.ID, cfg.secret, accessTokenTTL)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating login token")
 		return
@@ -208,19 +187,11 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := struct{
-		ID				uuid.UUID	`json:"id"`
-		CreatedAt		time.Time	`json:"created_at"`
-		UpdatedAt		time.Time	`json:"updated_at"`
-		Email			string		`json:"email"`
-		IsRed			bool		`json:"is_chirpy_red"`
+		User
 		Token			string		`json:"token"`
 		RefreshToken	string		`json:"refresh_token"`
 	}{
-		ID:				dbUser.ID,
-		CreatedAt:		dbUser.CreatedAt,
-		UpdatedAt:		dbUser.UpdatedAt,
-		Email:			dbUser.Email,
-		IsRed:			dbUser.IsChirpyRed,
+		User:			userFromDB(dbUser),
 		Token:			token,
 		RefreshToken: 	refreshToken,
 	}
@@ -258,13 +229,14 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 		return 
 	}
 	
-	response := User{
-		ID: 		user.ID,
-		CreatedAt:	user.CreatedAt,
-		UpdatedAt: 	user.UpdatedAt,
-		Email:		user.Email,
-		IsRed: 		user.IsChirpyRed,
-	}
+	// Signups go to admins' webhooks, which get the public profile rather
+	// than the user's email.
+	cfg.publishEvent(r.Context(), eventUserCreated, user.ID, Author{
+		ID:          user.ID,
+		Handle:      user.Handle.String,
+		DisplayName: user.DisplayName,
+		AvatarURL:   user.AvatarUrl,
+	})
 
-	utils.RespondWithJSON(w, http.StatusCreated, response)
+	utils.RespondWithJSON(w, http.StatusCreated, userFromDB(user))
 }
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/auth"
+	"github.com/tsyrdev/chirpy/internal/database"
+	"github.com/tsyrdev/chirpy/internal/netguard"
+	"github.com/tsyrdev/chirpy/internal/webhooks"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+// Events that outbound webhooks can subscribe to.
+const (
+	eventChirpCreated = "chirp.created"
+	eventChirpDeleted = "chirp.deleted"
+	eventUserCreated  = "user.created"
+)
+
+var webhookEventTypes = []string{eventChirpCreated, eventChirpDeleted, eventUserCreated}
+
+// Events about other users' accounts that only admins may subscribe to.
+var adminWebhookEventTypes = []string{eventUserCreated}
+
+const (
+	deliveryPending   = "pending"
+	deliveryDelivered = "delivered"
+	deliveryDead      = "dead"
+)
+
+type WebhookEndpoint struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+}
+
+func webhookEndpointFromDB(dbEndpoint database.WebhookEndpoint) WebhookEndpoint {
+	return WebhookEndpoint{
+		ID:         dbEndpoint.ID,
+		CreatedAt:  dbEndpoint.CreatedAt,
+		URL:        dbEndpoint.Url,
+		EventTypes: dbEndpoint.EventTypes,
+	}
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastStatusCode *int32          `json:"last_status_code"`
+	LastError      string          `json:"last_error"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+func webhookDeliveryFromDB(dbDelivery database.WebhookDelivery) WebhookDelivery {
+	delivery := WebhookDelivery{
+		ID:          dbDelivery.ID,
+		CreatedAt:   dbDelivery.CreatedAt,
+		EventType:   dbDelivery.EventType,
+		Payload:     json.RawMessage(dbDelivery.Payload),
+		Status:      dbDelivery.Status,
+		Attempts:    dbDelivery.Attempts,
+		LastError:   dbDelivery.LastError.String,
+		DeliveredAt: nullTimePtr(dbDelivery.DeliveredAt),
+	}
+	if dbDelivery.Status == deliveryPending {
+		delivery.NextAttemptAt = &dbDelivery.NextAttemptAt
+	}
+	if dbDelivery.LastStatusCode.Valid {
+		delivery.LastStatusCode = &dbDelivery.LastStatusCode.Int32
+	}
+	return delivery
+}
+
+// emitWebhookEvent queues an event for every endpoint subscribed to it.
+// userID is the account the event is about. Failures are logged rather than
+// failing the request that caused the event.
+func (cfg *apiConfig) emitWebhookEvent(ctx context.Context, eventType string, userID uuid.UUID, data any) {
+	if !slices.Contains(webhookEventTypes, eventType) {
+		return
+	}
+
+	payload, err := json.Marshal(struct {
+		ID        uuid.UUID `json:"id"`
+		Type      string    `json:"type"`
+		CreatedAt time.Time `json:"created_at"`
+		Data      any       `json:"data"`
+	}{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		log.Printf("Error encoding %s webhook event: %v", eventType, err)
+		return
+	}
+
+	_, err = cfg.dbQueries.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventType:       eventType,
+		Payload:         string(payload),
+		UserID:          userID,
+		AdminEventTypes: adminWebhookEventTypes,
+	})
+	if err != nil {
+		log.Printf("Error queueing %s webhook deliveries: %v", eventType, err)
+	}
+}
+
+// deliverWebhooks sends the deliveries that are due. Failed deliveries are
+// retried with exponential backoff until webhooks.MaxAttempts, after which
+// they are marked dead.
+func (cfg *apiConfig) deliverWebhooks(ctx context.Context) error {
+	dbDeliveries, err := cfg.dbQueries.ClaimWebhookDeliveries(ctx, 20)
+	if err != nil {
+		return err
+	}
+
+	for _, dbDelivery := range dbDeliveries {
+		statusCode, sendErr := cfg.sendWebhookDelivery(ctx, dbDelivery)
+
+		finish := database.FinishWebhookDeliveryParams{
+			ID:             dbDelivery.ID,
+			Status:         deliveryDelivered,
+			NextAttemptAt:  time.Now(),
+			LastStatusCode: sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0},
+		}
+		if sendErr != nil {
+			attempts := int(dbDelivery.Attempts) + 1
+			finish.Status = deliveryPending
+			finish.NextAttemptAt = time.Now().Add(webhooks.Backoff(attempts))
+			finish.LastError = sql.NullString{String: sendErr.Error(), Valid: true}
+			if attempts >= webhooks.MaxAttempts || errors.Is(sendErr, sql.ErrNoRows) {
+				finish.Status = deliveryDead
+			}
+		}
+		// One delivery failing to save mustn't hold back the rest of the
+		// batch. Its lease runs out and it is sent again.
+		if err := cfg.dbQueries.FinishWebhookDelivery(ctx, finish); err != nil {
+			log.Printf("Error saving webhook delivery %s: %v", dbDelivery.ID, err)
+		}
+	}
+	return nil
+}
+
+// sendWebhookDelivery sends a delivery to its endpoint. It returns
+// sql.ErrNoRows when the endpoint no longer exists.
+func (cfg *apiConfig) sendWebhookDelivery(ctx context.Context, dbDelivery database.WebhookDelivery) (int, error) {
+	dbEndpoint, err := cfg.dbQueries.GetWebhookEndpoint(ctx, dbDelivery.EndpointID)
+	if err != nil {
+		return 0, err
+	}
+	return cfg.webhookSender.Send(ctx, webhooks.Delivery{
+		ID:      dbDelivery.ID.String(),
+		URL:     dbEndpoint.Url,
+		Secret:  dbEndpoint.Secret,
+		Event:   dbDelivery.EventType,
+		Payload: []byte(dbDelivery.Payload),
+	})
+}
+
+// runWebhookDeliveries periodically sends queued webhook deliveries.
+func (cfg *apiConfig) runWebhookDeliveries(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := cfg.deliverWebhooks(context.Background()); err != nil {
+			log.Printf("Error delivering webhooks: %v", err)
+		}
+	}
+}
+
+func (cfg *apiConfig) handlerCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	var params struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	u, err := url.Parse(params.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Webhook URL must be an http(s) URL")
+		return
+	}
+	// Names resolving to internal addresses are refused when delivering.
+	if netguard.BlockedHost(u.Hostname()) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Webhook URL must not point to a private or local address")
+		return
+	}
+	if len(params.EventTypes) == 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Webhook needs at least one event type")
+		return
+	}
+
+	dbUser, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User could not be found")
+		return
+	}
+	for _, eventType := range params.EventTypes {
+		if !slices.Contains(webhookEventTypes, eventType) {
+			utils.RespondWithError(w, http.StatusBadRequest, "Unknown event type: "+eventType)
+			return
+		}
+		if slices.Contains(adminWebhookEventTypes, eventType) && !dbUser.IsAdmin {
+			utils.RespondWithError(w, http.StatusForbidden, "Only admins can subscribe to "+eventType)
+			return
+		}
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Error creating webhook secret")
+		return
+	}
+
+	dbEndpoint, err := cfg.dbQueries.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID:     userID,
+		Url:        params.URL,
+		Secret:     secret,
+		EventTypes: params.EventTypes,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not save webhook")
+		return
+	}
+
+	// The signing secret is only ever returned here.
+	response := struct {
+		WebhookEndpoint
+		Secret string `json:"secret"`
+	}{
+		WebhookEndpoint: webhookEndpointFromDB(dbEndpoint),
+		Secret:          secret,
+	}
+	utils.RespondWithJSON(w, http.StatusCreated, response)
+}
+
+func (cfg *apiConfig) handlerGetWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbEndpoints, err := cfg.dbQueries.GetUserWebhookEndpoints(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get webhooks")
+		return
+	}
+
+	endpoints := make([]WebhookEndpoint, 0, len(dbEndpoints))
+	for _, dbEndpoint := range dbEndpoints {
+		endpoints = append(endpoints, webhookEndpointFromDB(dbEndpoint))
+	}
+	utils.RespondWithJSON(w, http.StatusOK, endpoints)
+}
+
+func (cfg *apiConfig) handlerDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	webhookID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:     webhookID,
+		UserID: userID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not delete webhook")
+		return
+	}
+	if deleted == 0 {
+		utils.RespondWithError(w, http.StatusNotFound, "Webhook could not be found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	webhookID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User does not possess an access token")
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	dbEndpoint, err := cfg.dbQueries.GetWebhookEndpoint(r.Context(), webhookID)
+	if err != nil || dbEndpoint.UserID != userID {
+		utils.RespondWithError(w, http.StatusNotFound, "Webhook could not be found")
+		return
+	}
+
+	dbDeliveries, err := cfg.dbQueries.GetWebhookDeliveries(r.Context(), webhookID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not get webhook deliveries")
+		return
+	}
+
+	deliveries := make([]WebhookDelivery, 0, len(dbDeliveries))
+	for _, dbDelivery := range dbDeliveries {
+		deliveries = append(deliveries, webhookDeliveryFromDB(dbDelivery))
+	}
+	utils.RespondWithJSON(w, http.StatusOK, deliveries)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/database"
+)
+
+// createTestWebhook registers an endpoint for userID subscribed to
+// eventTypes. Nothing is sent to it unless a test runs deliverWebhooks.
+func createTestWebhook(t *testing.T, cfg *apiConfig, userID uuid.UUID, eventTypes ...string) database.WebhookEndpoint {
+	t.Helper()
+	dbEndpoint, err := cfg.dbQueries.CreateWebhookEndpoint(context.Background(), database.CreateWebhookEndpointParams{
+		UserID:     userID,
+		Url:        "https://example.com/hook",
+		Secret:     "test-secret",
+		EventTypes: eventTypes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dbEndpoint
+}
+
+func countWebhookDeliveries(t *testing.T, cfg *apiConfig, endpointID uuid.UUID, eventType string) int {
+	t.Helper()
+	dbDeliveries, err := cfg.dbQueries.GetWebhookDeliveries(context.Background(), endpointID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for _, dbDelivery := range dbDeliveries {
+		if dbDelivery.EventType == eventType {
+			count++
+		}
+	}
+	return count
+}
+
+func TestAdminWebhooksOnlyGetOtherUsersSignups(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+	admin, _ := createTestUser(t, cfg)
+	other, _ := createTestUser(t, cfg)
+	makeTestAdmin(t, cfg, admin.ID)
+	dbEndpoint := createTestWebhook(t, cfg, admin.ID, eventChirpCreated, eventUserCreated)
+
+	cfg.emitWebhookEvent(ctx, eventChirpCreated, other.ID, struct{}{})
+	cfg.emitWebhookEvent(ctx, eventUserCreated, other.ID, struct{}{})
+
+	if n := countWebhookDeliveries(t, cfg, dbEndpoint.ID, eventChirpCreated); n != 0 {
+		t.Errorf("expected no %s deliveries for another user's chirp, got %d", eventChirpCreated, n)
+	}
+	if n := countWebhookDeliveries(t, cfg, dbEndpoint.ID, eventUserCreated); n != 1 {
+		t.Errorf("expected 1 %s delivery, got %d", eventUserCreated, n)
+	}
+}
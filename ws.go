@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+	"github.com/tsyrdev/chirpy/internal/auth"
+	"github.com/tsyrdev/chirpy/internal/events"
+	"github.com/tsyrdev/chirpy/utils"
+)
+
+// eventNotificationCreated is published for the user a notification is
+// for and is delivered over their WebSocket connections.
+const eventNotificationCreated = "notification.created"
+
+// Events published for a single user, by the message type they are
+// delivered as over that user's WebSocket connections.
+var wsUserEvents = map[string]string{
+	eventNotificationCreated: "notification",
+	eventMessageCreated:      "message",
+	eventConversationRead:    "read_receipt",
+}
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPingInterval = 30 * time.Second
+	wsMaxMessage   = 4096
+	wsMaxFeeds     = 16
+	wsOutboxSize   = 16
+)
+
+// wsClientMessage is a message sent by the client:
+// {"type": "subscribe" | "unsubscribe", "feed": "global" | "timeline" | "author", "author_id": "..."}
+// or {"type": "ping"}.
+type wsClientMessage struct {
+	Type     string `json:"type"`
+	Feed     string `json:"feed"`
+	AuthorID string `json:"author_id"`
+}
+
+type wsServerMessage struct {
+	Type    string          `json:"type"`
+	Feed    string          `json:"feed,omitempty"`
+	Feeds   []string        `json:"feeds,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	ID      int64           `json:"id,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// wsSession tracks the feeds a WebSocket connection is subscribed to.
+// hidden holds the users the viewer blocked or muted, as of their latest
+// subscription.
+type wsSession struct {
+	userID uuid.UUID
+	mu     sync.RWMutex
+	feeds  map[string][]uuid.UUID
+	hidden []uuid.UUID
+	out    chan wsServerMessage
+}
+
+func wsFeedKey(msg wsClientMessage) string {
+	if msg.Feed == "author" {
+		return "author:" + msg.AuthorID
+	}
+	if msg.Feed == "" {
+		return "global"
+	}
+	return msg.Feed
+}
+
+// matchingFeeds returns the subscribed feeds an event belongs to.
+func (s *wsSession) matchingFeeds(e events.Event) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+	for key, authors := range s.feeds {
+		if feedFilter(authors, s.hidden)(e) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (s *wsSession) wants(e events.Event) bool {
+	if _, ok := wsUserEvents[e.Type]; ok || e.Type == eventAccountDisabled {
+		return e.UserID == s.userID
+	}
+	return len(s.matchingFeeds(e)) > 0
+}
+
+// send queues a reply to the client. It reports false when the client
+// isn't reading its replies fast enough.
+func (s *wsSession) send(msg wsServerMessage) bool {
+	select {
+	case s.out <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+func (cfg *apiConfig) handlerWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Browsers can't set headers on WebSocket requests, so the access token
+	// may also be passed as ?access_token.
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		token = r.URL.Query().Get("access_token")
+	}
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Access Token")
+		return
+	}
+
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(wsMaxMessage)
+
+	session := &wsSession{
+		userID: userID,
+		feeds:  make(map[string][]uuid.UUID),
+		out:    make(chan wsServerMessage, wsOutboxSize),
+	}
+	sub, _ := cfg.events.Subscribe(session.wants, 0)
+	defer sub.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		cfg.readWebSocket(ctx, conn, session)
+	}()
+	go func() {
+		defer cancel()
+		pingWebSocket(ctx, conn)
+	}()
+
+	for {
+		var msg wsServerMessage
+		select {
+		case <-ctx.Done():
+			conn.Close(websocket.StatusNormalClosure, "")
+			return
+		case msg = <-session.out:
+		case event, ok := <-sub.C:
+			// The hub drops subscribers that fall behind rather than
+			// buffering without limit. The client should reconnect.
+			if !ok {
+				conn.Close(websocket.StatusTryAgainLater, "client is too slow")
+				return
+			}
+			if event.Type == eventAccountDisabled {
+				conn.Close(websocket.StatusPolicyViolation, "account has been disabled")
+				return
+			}
+			msg = wsServerMessage{Type: "event", Event: event.Type, ID: event.ID, Data: event.Data}
+			if messageType, ok := wsUserEvents[event.Type]; ok {
+				msg.Type = messageType
+			} else {
+				msg.Feeds = session.matchingFeeds(event)
+			}
+		}
+
+		if err := writeWebSocket(ctx, conn, msg); err != nil {
+			return
+		}
+	}
+}
+
+func writeWebSocket(ctx context.Context, conn *websocket.Conn, msg wsServerMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+	defer cancel()
+	return conn.Write(ctx, websocket.MessageText, data)
+}
+
+// pingWebSocket checks that the client is still there, returning once it
+// stops answering pings.
+func pingWebSocket(ctx context.Context, conn *websocket.Conn) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+			err := conn.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readWebSocket handles the client's messages until the connection closes.
+func (cfg *apiConfig) readWebSocket(ctx context.Context, conn *websocket.Conn, session *wsSession) {
+	for {
+		typ, data, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+
+		var msg wsClientMessage
+		if typ != websocket.MessageText || json.Unmarshal(data, &msg) != nil {
+			if !session.send(wsServerMessage{Type: "error", Message: "Messages must be JSON text"}) {
+				return
+			}
+			continue
+		}
+
+		reply := cfg.handleWebSocketMessage(ctx, session, msg)
+		if !session.send(reply) {
+			return
+		}
+	}
+}
+
+func (cfg *apiConfig) handleWebSocketMessage(ctx context.Context, session *wsSession, msg wsClientMessage) wsServerMessage {
+	switch msg.Type {
+	case "ping":
+		return wsServerMessage{Type: "pong"}
+
+	case "subscribe":
+		authors, err := cfg.feedAuthors(ctx, msg.Feed, msg.AuthorID, session.userID)
+		if err != nil {
+			message := "Could not get the feed"
+			if errors.Is(err, errUnknownFeed) || errors.Is(err, errFeedNeedsAuthor) {
+				_, message = feedErrorStatus(err)
+			}
+			return wsServerMessage{Type: "error", Feed: msg.Feed, Message: message}
+		}
+		hidden, err := cfg.hiddenUsers(ctx, session.userID)
+		if err != nil {
+			return wsServerMessage{Type: "error", Feed: msg.Feed, Message: "Could not get the feed"}
+		}
+
+		key := wsFeedKey(msg)
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		if _, ok := session.feeds[key]; !ok && len(session.feeds) >= wsMaxFeeds {
+			return wsServerMessage{Type: "error", Feed: key, Message: "Too many subscriptions"}
+		}
+		session.feeds[key] = authors
+		session.hidden = hidden
+		return wsServerMessage{Type: "subscribed", Feed: key}
+
+	case "unsubscribe":
+		key := wsFeedKey(msg)
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		delete(session.feeds, key)
+		return wsServerMessage{Type: "unsubscribed", Feed: key}
+
+	default:
+		return wsServerMessage{Type: "error", Message: "Unknown message type"}
+	}
+}